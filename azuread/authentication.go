@@ -1,13 +1,18 @@
 package azuread
 
 import (
+	"azure-adt-example/logging"
+	"azure-adt-example/pipeline"
+	"azure-adt-example/retry"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // AccessToken represents a response from the Azure token authority.
@@ -21,44 +26,171 @@ type AccessToken struct {
 	AccessToken  string `json:"access_token"`
 }
 
+// UnmarshalJSON parses both the v1 token response, where the times are strings, and the v2.0
+// response, where they are numbers. The v2.0 response does not include "expires_on", so it is
+// calculated from "expires_in".
+func (t *AccessToken) UnmarshalJSON(data []byte) error {
+	var response struct {
+		TokenType    string      `json:"token_type"`
+		ExpiresIn    tokenNumber `json:"expires_in"`
+		ExtExpiresIn tokenNumber `json:"ext_expires_in"`
+		ExpiresOn    tokenNumber `json:"expires_on"`
+		NotBefore    tokenNumber `json:"not_before"`
+		Resource     string      `json:"resource"`
+		AccessToken  string      `json:"access_token"`
+	}
+
+	if err := json.Unmarshal(data, &response); err != nil {
+		return err
+	}
+
+	*t = AccessToken{
+		TokenType:    response.TokenType,
+		ExpiresIn:    int64(response.ExpiresIn),
+		ExtExpiresIn: int64(response.ExtExpiresIn),
+		ExpiresOn:    int64(response.ExpiresOn),
+		NotBefore:    int64(response.NotBefore),
+		Resource:     response.Resource,
+		AccessToken:  response.AccessToken,
+	}
+
+	if t.ExpiresOn == 0 && t.ExpiresIn > 0 {
+		t.ExpiresOn = time.Now().Unix() + t.ExpiresIn
+	}
+
+	return nil
+}
+
+// GetToken returns the access token until it expires, so that a token acquired elsewhere can be
+// used as a TokenCredential.
+func (t *AccessToken) GetToken(context.Context) (*AccessToken, error) {
+	if t.ExpiresOn <= time.Now().Unix() {
+		return nil, fmt.Errorf("access token expired at %s", time.Unix(t.ExpiresOn, 0).UTC().Format(time.RFC3339))
+	}
+	return t, nil
+}
+
+// tokenNumber is a number in a token response which may be sent as a JSON number or string.
+type tokenNumber int64
+
+func (n *tokenNumber) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "" || value == "null" {
+		*n = 0
+		return nil
+	}
+
+	parsed, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%s is not a valid number", string(data))
+	}
+
+	*n = tokenNumber(parsed)
+	return nil
+}
+
 // GetBearerToken retrieves a token scoped to the Azure Digital Twin resource.
 func GetBearerToken(configuration *TwinConfiguration) (*AccessToken, error) {
-	log.Printf("Attempting to acquire access token for resource: %s", configuration.ResourceId)
+	return GetBearerTokenCtx(context.Background(), configuration)
+}
 
-	authenticationUrl := fmt.Sprintf("%s/%s/oauth2/token", configuration.AuthorityUrl.String(), configuration.TenantId)
+// GetBearerTokenCtx retrieves a token scoped to the Azure Digital Twin resource. The
+// request to the authority is aborted if the context is cancelled.
+func GetBearerTokenCtx(ctx context.Context, configuration *TwinConfiguration) (*AccessToken, error) {
+	return NewClientSecretCredential(configuration).GetToken(ctx)
+}
+
+// tokenTarget identifies the token endpoint of a tenant, and what the token is requested for.
+// The v1 endpoint issues tokens for a resource, and the v2.0 endpoint for a scope.
+type tokenTarget struct {
+	authorityUrl url.URL
+	tenantId     string
+	version      TokenEndpointVersion
+	resourceId   string
+	scope        string
+}
+
+// endpoint generates the URL of the token endpoint for the tenant.
+func (t tokenTarget) endpoint() string {
+	if t.version == TokenEndpointV2 {
+		return fmt.Sprintf("%s/%s/oauth2/v2.0/token", t.authorityUrl.String(), t.tenantId)
+	}
+	return fmt.Sprintf("%s/%s/oauth2/token", t.authorityUrl.String(), t.tenantId)
+}
+
+// cacheKey identifies the tokens acquired for the client from the target.
+func (t tokenTarget) cacheKey(clientId string) string {
+	return fmt.Sprintf("%s|%s|%s|%s", t.authorityUrl.String(), t.tenantId, clientId, t.audience())
+}
+
+// audience returns the resource or scope which the token is requested for.
+func (t tokenTarget) audience() string {
+	if t.version == TokenEndpointV2 {
+		if t.scope == "" {
+			return t.resourceId + "/.default"
+		}
+		return t.scope
+	}
+	return t.resourceId
+}
 
-	data := url.Values{}
-	data.Add("client_id", configuration.ClientId)
-	data.Add("client_secret", configuration.ClientSecret)
-	data.Add("resource", configuration.ResourceId)
-	data.Add("grant_type", "client_credentials")
+// requestClientCredentialsToken posts a client credentials grant to the token endpoint. The
+// data holds the values which identify the client, such as a client secret or assertion.
+func requestClientCredentialsToken(ctx context.Context, client *http.Client, policy *retry.Policy, logger logging.Logger, target tokenTarget, data url.Values) (*AccessToken, error) {
+	if target.version == TokenEndpointV2 {
+		data.Set("scope", target.audience())
+	} else {
+		data.Set("resource", target.audience())
+	}
+	data.Set("grant_type", "client_credentials")
 
-	client := &http.Client{}
-	resp, err := client.PostForm(authenticationUrl, data)
+	req, err := http.NewRequestWithContext(ctx, "POST", target.endpoint(), strings.NewReader(data.Encode()))
 	if err != nil {
-		return nil, fmt.Errorf("unable to obtain access token: %v", err)
-	} else if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("received error response from authority url: %d", resp.StatusCode)
+		return nil, fmt.Errorf("unable to create token request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	return requestToken(client, policy, logger, req, target.audience())
+}
+
+// requestToken sends a request for an access token using the client, retrying it according to
+// the policy, and parses the response. The client and logger may be nil.
+func requestToken(client *http.Client, policy *retry.Policy, logger logging.Logger, req *http.Request, audience string) (*AccessToken, error) {
+	logger = logging.OrDiscard(logger)
+	logger.Log(logging.LevelDebug, "requesting access token", logging.F(logging.FieldAudience, audience))
+	start := time.Now()
+
+	if client == nil {
+		client = pipeline.DefaultHTTPClient
+	}
+	// Token requests do not change anything, so are safe to retry after a transport error
+	req = req.WithContext(retry.WithIdempotent(req.Context()))
+	resp, err := policy.Do(req, client.Do)
+	if err != nil {
+		logger.Log(logging.LevelError, "unable to obtain access token", logging.F(logging.FieldAudience, audience), logging.F(logging.FieldError, err))
+		return nil, fmt.Errorf("unable to obtain access token: %w", err)
 	}
 	defer func(Body io.ReadCloser) {
+		// The body is drained so that the connection can be reused
+		_, _ = io.Copy(io.Discard, Body)
 		err := Body.Close()
 		if err != nil {
-			log.Printf("Unable to close body: %v", err)
+			logger.Log(logging.LevelWarn, "unable to close body", logging.F(logging.FieldError, err))
 		}
 	}(resp.Body)
 
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("unable to read response body: %v", err)
+	if resp.StatusCode != 200 {
+		authError := newAuthError(resp)
+		logger.Log(logging.LevelError, "received error response from authority", logging.F(logging.FieldAudience, audience), logging.F(logging.FieldStatus, resp.StatusCode), logging.F(logging.FieldError, authError))
+		return nil, authError
 	}
 
 	var response AccessToken
-	err = json.Unmarshal(bodyBytes, &response)
-	if err != nil {
+	if err = json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return nil, fmt.Errorf("unable to parse response: %v", err)
 	}
 
-	log.Print("Successfully acquired access token")
+	logger.Log(logging.LevelInfo, "acquired access token", logging.F(logging.FieldAudience, audience), logging.F(logging.FieldDuration, time.Since(start)))
 
 	return &response, nil
 }
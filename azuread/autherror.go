@@ -0,0 +1,81 @@
+package azuread
+
+import (
+	"azure-adt-example/retry"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBytes limits how much of an error response is read, as only the OAuth 2.0 error at
+// its start is used.
+const maxErrorBytes = 4096
+
+// AuthError is returned when the authority, or a managed identity endpoint, responds to a token
+// request with an error. The values are parsed from the OAuth 2.0 error response, and are empty
+// if the response did not contain one.
+type AuthError struct {
+	// StatusCode of the response from the authority.
+	StatusCode int `json:"-"`
+
+	// ErrorCode is the OAuth 2.0 error, such as invalid_client or invalid_scope.
+	ErrorCode string `json:"error"`
+
+	// Description explains the error, starting with the AADSTS code of the error.
+	Description string `json:"error_description"`
+
+	// ErrorCodes are the numeric AADSTS codes of the error.
+	ErrorCodes []int `json:"error_codes"`
+
+	Timestamp     string `json:"timestamp"`
+	TraceId       string `json:"trace_id"`
+	CorrelationId string `json:"correlation_id"`
+	ErrorUri      string `json:"error_uri"`
+}
+
+func (e *AuthError) Error() string {
+	message := fmt.Sprintf("received error response from authority url: %d", e.StatusCode)
+	if e.ErrorCode != "" {
+		message = fmt.Sprintf("%s: %s", message, e.ErrorCode)
+	}
+	if e.Description != "" {
+		// The description continues with the trace and correlation ids on separate lines
+		description, _, _ := strings.Cut(e.Description, "\n")
+		message = fmt.Sprintf("%s: %s", message, strings.TrimSpace(description))
+	}
+	return message
+}
+
+// AADSTSCode returns the code identifying the error, such as AADSTS7000215 for an invalid
+// client secret, or an empty string if the response did not include one.
+func (e *AuthError) AADSTSCode() string {
+	if len(e.ErrorCodes) > 0 {
+		return fmt.Sprintf("AADSTS%d", e.ErrorCodes[0])
+	}
+	if code, _, found := strings.Cut(e.Description, ":"); found && strings.HasPrefix(code, "AADSTS") {
+		return code
+	}
+	return ""
+}
+
+// IsRetryable returns true if the request failed because of a transient error, and may
+// succeed if it is sent again.
+func (e *AuthError) IsRetryable() bool {
+	return e.ErrorCode == "temporarily_unavailable" || retry.DefaultPolicy().IsRetryableStatus(e.StatusCode)
+}
+
+// newAuthError creates the error for a non-success token response, parsing the OAuth 2.0 error
+// from the body if it contains one.
+func newAuthError(resp *http.Response) *AuthError {
+	authError := &AuthError{}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBytes))
+	if err == nil {
+		_ = json.Unmarshal(content, authError)
+	}
+
+	authError.StatusCode = resp.StatusCode
+	return authError
+}
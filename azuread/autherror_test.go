@@ -0,0 +1,83 @@
+package azuread
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientSecretCredential_GetToken_AuthError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{
+			"error": "invalid_client",
+			"error_description": "AADSTS7000215: Invalid client secret provided.\r\nTrace ID: trace1\r\nCorrelation ID: correlation1",
+			"error_codes": [7000215],
+			"trace_id": "trace1",
+			"correlation_id": "correlation1"
+		}`)
+	}))
+	defer server.Close()
+
+	_, err := NewClientSecretCredential(newTestConfiguration(server)).GetToken(context.Background())
+
+	var authError *AuthError
+	if !errors.As(err, &authError) {
+		t.Fatalf("Expected an AuthError, but got %v", err)
+	}
+
+	expected := "received error response from authority url: 401: invalid_client: AADSTS7000215: Invalid client secret provided."
+	if err.Error() != expected {
+		t.Errorf("Expected error '%s', but got '%s'", expected, err.Error())
+	}
+
+	if authError.StatusCode != http.StatusUnauthorized || authError.ErrorCode != "invalid_client" || authError.TraceId != "trace1" || authError.CorrelationId != "correlation1" {
+		t.Errorf("Unexpected error %+v", authError)
+	}
+	if authError.AADSTSCode() != "AADSTS7000215" {
+		t.Errorf("Expected code AADSTS7000215, but got %s", authError.AADSTSCode())
+	}
+	if authError.IsRetryable() {
+		t.Error("Expected an invalid client to not be retryable")
+	}
+}
+
+func TestAuthError(t *testing.T) {
+	tests := []struct {
+		authError AuthError
+		message   string
+		code      string
+		retryable bool
+	}{
+		{AuthError{StatusCode: 400}, "received error response from authority url: 400", "", false},
+		{AuthError{StatusCode: 400, Description: "AADSTS90002: Tenant not found."}, "received error response from authority url: 400: AADSTS90002: Tenant not found.", "AADSTS90002", false},
+		{AuthError{StatusCode: 503, ErrorCode: "temporarily_unavailable"}, "received error response from authority url: 503: temporarily_unavailable", "", true},
+		{AuthError{StatusCode: 429}, "received error response from authority url: 429", "", true},
+	}
+
+	for _, test := range tests {
+		if actual := test.authError.Error(); actual != test.message {
+			t.Errorf("Expected message '%s', but got '%s'", test.message, actual)
+		}
+		if actual := test.authError.AADSTSCode(); actual != test.code {
+			t.Errorf("Expected code '%s' for '%s', but got '%s'", test.code, test.message, actual)
+		}
+		if actual := test.authError.IsRetryable(); actual != test.retryable {
+			t.Errorf("Expected retryable %v for '%s', but got %v", test.retryable, test.message, actual)
+		}
+	}
+}
+
+func TestNewAuthError_LargeBody(t *testing.T) {
+	body := strings.NewReader(`{ "error": "server_error" }` + strings.Repeat(" ", 10*maxErrorBytes))
+	authError := newAuthError(&http.Response{StatusCode: http.StatusInternalServerError, Body: io.NopCloser(body)})
+
+	if authError.StatusCode != http.StatusInternalServerError || body.Len() < 9*maxErrorBytes {
+		t.Errorf("Expected at most %d bytes of the body to be read, but %d remain", maxErrorBytes, body.Len())
+	}
+}
@@ -0,0 +1,99 @@
+package azuread
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// AzureCLICredential acquires tokens for the user signed in to the Azure CLI, which is useful
+// when running locally.
+type AzureCLICredential struct {
+	ResourceId string
+
+	// TenantId to request the token from. If empty then the default tenant of the signed in
+	// account is used.
+	TenantId string
+
+	// runCommand runs the Azure CLI, allowing it to be replaced when testing.
+	runCommand func(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// cliToken defines the output of "az account get-access-token".
+type cliToken struct {
+	AccessToken string `json:"accessToken"`
+	ExpiresOn   string `json:"expiresOn"`
+	ExpiresOnTs int64  `json:"expires_on"`
+	TokenType   string `json:"tokenType"`
+}
+
+// NewAzureCLICredential creates an AzureCLICredential for the resource and tenant in the configuration.
+func NewAzureCLICredential(configuration *TwinConfiguration) *AzureCLICredential {
+	return &AzureCLICredential{ResourceId: configuration.ResourceId, TenantId: configuration.TenantId}
+}
+
+func (c *AzureCLICredential) CacheKey() string {
+	return fmt.Sprintf("%s|%s", c.TenantId, c.ResourceId)
+}
+
+func (c *AzureCLICredential) GetToken(ctx context.Context) (*AccessToken, error) {
+	args := []string{"account", "get-access-token", "--resource", c.ResourceId, "--output", "json"}
+	if c.TenantId != "" {
+		args = append(args, "--tenant", c.TenantId)
+	}
+
+	run := c.runCommand
+	if run == nil {
+		run = runCommand
+	}
+
+	output, err := run(ctx, "az", args...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to obtain access token from the Azure CLI: %w", err)
+	}
+
+	var token cliToken
+	if err = json.Unmarshal(output, &token); err != nil {
+		return nil, fmt.Errorf("unable to parse Azure CLI response: %v", err)
+	}
+
+	expiresOn := token.ExpiresOnTs
+	if expiresOn == 0 {
+		// Older versions of the Azure CLI only return the expiry as a local time
+		expiry, err := time.ParseInLocation("2006-01-02 15:04:05.999999", token.ExpiresOn, time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse Azure CLI token expiry %s: %v", token.ExpiresOn, err)
+		}
+		expiresOn = expiry.Unix()
+	}
+
+	return &AccessToken{
+		TokenType:   token.TokenType,
+		ExpiresIn:   expiresOn - time.Now().Unix(),
+		ExpiresOn:   expiresOn,
+		Resource:    c.ResourceId,
+		AccessToken: token.AccessToken,
+	}, nil
+}
+
+// runCommand runs the command, including anything written to stderr in the returned error.
+func runCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("%v: %s", err, message)
+		}
+		return nil, err
+	}
+
+	return output, nil
+}
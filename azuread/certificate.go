@@ -0,0 +1,194 @@
+package azuread
+
+import (
+	"azure-adt-example/logging"
+	"azure-adt-example/pipeline"
+	"azure-adt-example/retry"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// clientAssertionType is the type of client assertion sent in place of a client secret.
+const clientAssertionType = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+
+// assertionLifetime is how long a signed client assertion remains valid.
+const assertionLifetime = 10 * time.Minute
+
+// ClientCertificateCredential acquires tokens for a service principal using a certificate
+// registered with the application. A client assertion signed by the private key of the
+// certificate is sent in place of a client secret.
+type ClientCertificateCredential struct {
+	TenantId     string
+	ClientId     string
+	Certificate  *x509.Certificate
+	PrivateKey   *rsa.PrivateKey
+	ResourceId   string
+	AuthorityUrl url.URL
+	RetryPolicy  *retry.Policy
+	Logger       logging.Logger
+	HTTPClient   *http.Client
+
+	// TokenEndpointVersion selects the v1 or v2.0 token endpoint, defaulting to v1.
+	TokenEndpointVersion TokenEndpointVersion
+
+	// Scope requested from the v2.0 endpoint. If empty then the default scope of the ResourceId is used.
+	Scope string
+}
+
+// NewClientCertificateCredential creates a ClientCertificateCredential for the service
+// principal defined in the configuration, ignoring any client secret.
+func NewClientCertificateCredential(configuration *TwinConfiguration, certificate *x509.Certificate, privateKey *rsa.PrivateKey) *ClientCertificateCredential {
+	return &ClientCertificateCredential{
+		TenantId:     configuration.TenantId,
+		ClientId:     configuration.ClientId,
+		Certificate:  certificate,
+		PrivateKey:   privateKey,
+		ResourceId:   configuration.ResourceId,
+		AuthorityUrl: configuration.AuthorityUrl,
+		RetryPolicy:  configuration.GetRetryPolicy(),
+		Logger:       configuration.GetLogger(),
+		HTTPClient:   configuration.HTTPClient,
+
+		TokenEndpointVersion: configuration.GetTokenEndpointVersion(),
+		Scope:                configuration.Scope,
+	}
+}
+
+// ParseCertificatePEM reads a certificate and its RSA private key from PEM encoded data, such
+// as a certificate exported from Azure Key Vault. The key may be in PKCS #1 or PKCS #8 form.
+func ParseCertificatePEM(data []byte) (*x509.Certificate, *rsa.PrivateKey, error) {
+	var certificate *x509.Certificate
+	var privateKey *rsa.PrivateKey
+
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		switch block.Type {
+		case "CERTIFICATE":
+			if certificate != nil {
+				continue
+			}
+			parsed, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to parse certificate: %v", err)
+			}
+			certificate = parsed
+		case "RSA PRIVATE KEY":
+			parsed, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to parse private key: %v", err)
+			}
+			privateKey = parsed
+		case "PRIVATE KEY":
+			parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unable to parse private key: %v", err)
+			}
+			key, ok := parsed.(*rsa.PrivateKey)
+			if !ok {
+				return nil, nil, fmt.Errorf("private key of type %T is not supported, an RSA key is required", parsed)
+			}
+			privateKey = key
+		}
+	}
+
+	if certificate == nil || privateKey == nil {
+		return nil, nil, fmt.Errorf("data must contain a certificate and its private key")
+	}
+
+	return certificate, privateKey, nil
+}
+
+func (c *ClientCertificateCredential) CacheKey() string {
+	return tokenTarget{c.AuthorityUrl, c.TenantId, c.TokenEndpointVersion, c.ResourceId, c.Scope}.cacheKey(c.ClientId)
+}
+
+func (c *ClientCertificateCredential) WithInterceptors(interceptors ...pipeline.Interceptor) TokenCredential {
+	intercepted := *c
+	intercepted.HTTPClient = interceptedClient(c.HTTPClient, interceptors)
+	return &intercepted
+}
+
+func (c *ClientCertificateCredential) GetToken(ctx context.Context) (*AccessToken, error) {
+	if c.Certificate == nil || c.PrivateKey == nil {
+		return nil, fmt.Errorf("a certificate and private key are required")
+	}
+
+	target := tokenTarget{c.AuthorityUrl, c.TenantId, c.TokenEndpointVersion, c.ResourceId, c.Scope}
+
+	assertion, err := c.newAssertion(target.endpoint(), time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	data := url.Values{}
+	data.Add("client_id", c.ClientId)
+	data.Add("client_assertion_type", clientAssertionType)
+	data.Add("client_assertion", assertion)
+
+	return requestClientCredentialsToken(ctx, c.HTTPClient, c.RetryPolicy, c.Logger, target, data)
+}
+
+// newAssertion creates a JSON Web Token for the token endpoint, signed using RS256. The
+// certificate is identified by the "x5t" header holding its SHA-1 thumbprint.
+func (c *ClientCertificateCredential) newAssertion(audience string, now time.Time) (string, error) {
+	thumbprint := sha1.Sum(c.Certificate.Raw)
+
+	var jwtId [16]byte
+	if _, err := rand.Read(jwtId[:]); err != nil {
+		return "", fmt.Errorf("unable to generate assertion id: %v", err)
+	}
+
+	header := map[string]string{
+		"alg": "RS256",
+		"typ": "JWT",
+		"x5t": base64.RawURLEncoding.EncodeToString(thumbprint[:]),
+	}
+	claims := map[string]any{
+		"aud": audience,
+		"iss": c.ClientId,
+		"sub": c.ClientId,
+		"jti": hex.EncodeToString(jwtId[:]),
+		"nbf": now.Unix(),
+		"exp": now.Add(assertionLifetime).Unix(),
+	}
+
+	encodedHeader, err := encodeSegment(header)
+	if err != nil {
+		return "", err
+	}
+	encodedClaims, err := encodeSegment(claims)
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := encodedHeader + "." + encodedClaims
+	digest := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.PrivateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("unable to sign client assertion: %v", err)
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// encodeSegment serialises a JSON Web Token segment using base64url encoding.
+func encodeSegment(value any) (string, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("unable to serialise client assertion: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(content), nil
+}
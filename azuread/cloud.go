@@ -0,0 +1,101 @@
+package azuread
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Cloud defines the values which differ between the Azure clouds, such as the public cloud and
+// the sovereign clouds.
+type Cloud struct {
+	// Name of the cloud, used when reporting problems.
+	Name string
+
+	// AuthorityUrl is the base url for obtaining an access token.
+	AuthorityUrl string
+
+	// ResourceId of Azure Digital Twin, requested from the v1 token endpoint.
+	ResourceId string
+
+	// Scope of Azure Digital Twin, requested from the v2.0 token endpoint.
+	Scope string
+
+	// HostSuffix which the host of every Azure Digital Twin instance in the cloud ends with. If
+	// empty then the host is not checked.
+	HostSuffix string
+}
+
+var (
+	// AzurePublic is the global Azure cloud.
+	AzurePublic = Cloud{
+		Name:         "AzurePublic",
+		AuthorityUrl: AuthorityUrl,
+		ResourceId:   ResourceId,
+		Scope:        Scope,
+		HostSuffix:   "digitaltwins.azure.net",
+	}
+
+	// AzureUSGovernment is the Azure cloud for US government agencies.
+	AzureUSGovernment = Cloud{
+		Name:         "AzureUSGovernment",
+		AuthorityUrl: "https://login.microsoftonline.us",
+		ResourceId:   "https://digitaltwins.azure.us",
+		Scope:        "https://digitaltwins.azure.us/.default",
+		HostSuffix:   "digitaltwins.azure.us",
+	}
+
+	// AzureChina is the Azure cloud operated by 21Vianet.
+	AzureChina = Cloud{
+		Name:         "AzureChina",
+		AuthorityUrl: "https://login.chinacloudapi.cn",
+		ResourceId:   "https://digitaltwins.azure.cn",
+		Scope:        "https://digitaltwins.azure.cn/.default",
+		HostSuffix:   "digitaltwins.azure.cn",
+	}
+)
+
+// cloudNames maps the names which can be used to select a predefined cloud, including those
+// used by the Azure CLI, to the cloud.
+var cloudNames = map[string]Cloud{
+	"public":            AzurePublic,
+	"azurepublic":       AzurePublic,
+	"azurecloud":        AzurePublic,
+	"usgovernment":      AzureUSGovernment,
+	"azureusgovernment": AzureUSGovernment,
+	"china":             AzureChina,
+	"azurechina":        AzureChina,
+	"azurechinacloud":   AzureChina,
+}
+
+// customCloud is the name used to select a cloud where every value is configured explicitly.
+const customCloud = "custom"
+
+// ParseCloud finds the predefined cloud with the given name, ignoring case. The names public,
+// usgovernment and china are accepted, as are the names used by the Azure CLI.
+func ParseCloud(name string) (Cloud, error) {
+	cloud, ok := cloudNames[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		names := make([]string, 0, len(cloudNames))
+		for n := range cloudNames {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Cloud{}, fmt.Errorf("%s is not a known cloud, expected %s, or %s", name, strings.Join(names, ", "), customCloud)
+	}
+	return cloud, nil
+}
+
+// MatchesHost is true if the URL is for an Azure Digital Twin instance in the cloud, or the
+// cloud does not define a host suffix.
+func (c Cloud) MatchesHost(twinUrl url.URL) bool {
+	if c.HostSuffix == "" {
+		return true
+	}
+
+	host := strings.ToLower(twinUrl.Hostname())
+	suffix := strings.ToLower(strings.TrimPrefix(c.HostSuffix, "."))
+
+	return host == suffix || strings.HasSuffix(host, "."+suffix)
+}
@@ -0,0 +1,141 @@
+package azuread
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseCloud(t *testing.T) {
+	tests := map[string]Cloud{
+		"public":            AzurePublic,
+		"AzureCloud":        AzurePublic,
+		" USGovernment ":    AzureUSGovernment,
+		"AzureUSGovernment": AzureUSGovernment,
+		"china":             AzureChina,
+		"AzureChinaCloud":   AzureChina,
+	}
+
+	for name, expected := range tests {
+		cloud, err := ParseCloud(name)
+		if err != nil {
+			t.Errorf("Expected nil error for %s, but got %v", name, err)
+		} else if cloud != expected {
+			t.Errorf("Expected %s for %s, but got %s", expected.Name, name, cloud.Name)
+		}
+	}
+
+	if _, err := ParseCloud("germany"); err == nil || !strings.Contains(err.Error(), "germany is not a known cloud") {
+		t.Errorf("Expected unknown cloud error, but got %v", err)
+	}
+}
+
+func TestCloud_MatchesHost(t *testing.T) {
+	tests := []struct {
+		cloud    Cloud
+		host     string
+		expected bool
+	}{
+		{AzurePublic, "example.api.weu.digitaltwins.azure.net", true},
+		{AzurePublic, "EXAMPLE.api.weu.DigitalTwins.Azure.Net:443", true},
+		{AzurePublic, "example.api.usgv.digitaltwins.azure.us", false},
+		{AzurePublic, "example.notdigitaltwins.azure.net", false},
+		{AzureUSGovernment, "example.api.usgv.digitaltwins.azure.us", true},
+		{AzureChina, "example.api.cne2.digitaltwins.azure.cn", true},
+		{Cloud{Name: customCloud}, "localhost:8080", true},
+	}
+
+	for _, test := range tests {
+		if actual := test.cloud.MatchesHost(url.URL{Scheme: "https", Host: test.host}); actual != test.expected {
+			t.Errorf("Expected %v for %s in %s, but got %v", test.expected, test.host, test.cloud.Name, actual)
+		}
+	}
+}
+
+func TestLoadTwinConfiguration_Cloud(t *testing.T) {
+	clearEnvironment(t)
+	t.Setenv("TWIN_URL", "https://example.api.usgv.digitaltwins.azure.us")
+	t.Setenv("TWIN_CLOUD", "usgovernment")
+
+	configuration, err := LoadTwinConfiguration(LoadOptions{EnvFiles: []string{}, IdentityOptional: true, StrictCloud: true})
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if configuration.Cloud != AzureUSGovernment {
+		t.Errorf("Expected the US government cloud, but got %+v", configuration.Cloud)
+	}
+	if configuration.AuthorityUrl.String() != AzureUSGovernment.AuthorityUrl || configuration.ResourceId != AzureUSGovernment.ResourceId || configuration.Scope != AzureUSGovernment.Scope {
+		t.Errorf("Unexpected cloud defaults %+v", configuration)
+	}
+
+	// Values set explicitly replace the defaults of the cloud.
+	t.Setenv("TWIN_RESOURCE_ID", "https://override")
+	configuration, err = LoadTwinConfiguration(LoadOptions{EnvFiles: []string{}, IdentityOptional: true})
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	if configuration.ResourceId != "https://override" || configuration.Cloud.ResourceId != "https://override" || configuration.Scope != AzureUSGovernment.Scope {
+		t.Errorf("Expected the resource to be overridden, but got %+v", configuration)
+	}
+}
+
+func TestLoadTwinConfiguration_CustomCloud(t *testing.T) {
+	clearEnvironment(t)
+	file := writeFile(t, "twin.yaml", `cloud: custom
+url: https://twins.example.com
+authorityUrl: https://login.example.com
+resourceId: https://twins.example.com
+hostSuffix: example.com
+`)
+
+	configuration, err := LoadTwinConfiguration(LoadOptions{EnvFiles: []string{}, File: file, IdentityOptional: true, StrictCloud: true})
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	expected := Cloud{Name: customCloud, AuthorityUrl: "https://login.example.com", ResourceId: "https://twins.example.com", HostSuffix: "example.com"}
+	if configuration.Cloud != expected || configuration.Scope != "" {
+		t.Errorf("Unexpected cloud %+v", configuration.Cloud)
+	}
+
+	_, err = LoadTwinConfiguration(LoadOptions{EnvFiles: []string{}, IdentityOptional: true, Values: ConfigurationValues{URL: "https://twins.example.com", Cloud: "custom"}})
+
+	var configurationError *ConfigurationError
+	if !errors.As(err, &configurationError) {
+		t.Fatalf("Expected a ConfigurationError, but got %v", err)
+	}
+	problems := []string{
+		"authorityUrl is required for a custom cloud (TWIN_AUTHORITY_URL)",
+		"resourceId is required for a custom cloud (TWIN_RESOURCE_ID)",
+	}
+	if !reflect.DeepEqual(configurationError.Problems, problems) {
+		t.Errorf("Unexpected problems\n%s", strings.Join(configurationError.Problems, "\n"))
+	}
+}
+
+func TestLoadTwinConfiguration_CloudMismatch(t *testing.T) {
+	clearEnvironment(t)
+	t.Setenv("TWIN_URL", "https://example.api.cne2.digitaltwins.azure.cn")
+
+	configuration, err := LoadTwinConfiguration(LoadOptions{EnvFiles: []string{}, IdentityOptional: true})
+	if err != nil {
+		t.Fatalf("Expected only a warning, but got %v", err)
+	}
+	if err = configuration.CheckCloud(); err == nil || !strings.Contains(err.Error(), "does not match the AzurePublic cloud, expected a host ending in .digitaltwins.azure.net") {
+		t.Errorf("Expected a cloud mismatch, but got %v", err)
+	}
+
+	_, err = LoadTwinConfiguration(LoadOptions{EnvFiles: []string{}, IdentityOptional: true, StrictCloud: true})
+	if err == nil || !strings.Contains(err.Error(), "url host example.api.cne2.digitaltwins.azure.cn does not match the AzurePublic cloud") {
+		t.Errorf("Expected a strict cloud mismatch error, but got %v", err)
+	}
+
+	t.Setenv("TWIN_CLOUD", "moon")
+	_, err = LoadTwinConfiguration(LoadOptions{EnvFiles: []string{}, IdentityOptional: true})
+	if err == nil || !strings.Contains(err.Error(), "moon is not a known cloud") || strings.Contains(err.Error(), "is required for a custom cloud") {
+		t.Errorf("Expected only the unknown cloud problem, but got %v", err)
+	}
+}
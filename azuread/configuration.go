@@ -1,17 +1,63 @@
 package azuread
 
 import (
-	"github.com/subosito/gotenv"
+	"azure-adt-example/logging"
+	"azure-adt-example/pipeline"
+	"azure-adt-example/retry"
+	"fmt"
 	"log"
+	"net/http"
 	"net/url"
-	"os"
+	"strings"
 )
 
 const (
 	ResourceId   = "0b07f429-9f4b-4714-9392-cc5e8e80c8b0"
 	AuthorityUrl = "https://login.microsoftonline.com"
+
+	// Scope is the scope requested from the v2.0 token endpoint for the Azure Digital Twin resource.
+	Scope = "https://digitaltwins.azure.net/.default"
+)
+
+// TokenEndpointVersion defines which version of the Microsoft identity platform token endpoint
+// is used to acquire access tokens.
+type TokenEndpointVersion int
+
+const (
+	// TokenEndpointV1 is the legacy /oauth2/token endpoint, which issues tokens for a resource.
+	TokenEndpointV1 TokenEndpointVersion = iota + 1
+
+	// TokenEndpointV2 is the /oauth2/v2.0/token endpoint, which issues tokens for a scope.
+	TokenEndpointV2
 )
 
+func (v TokenEndpointVersion) String() string {
+	versions := []string{"v1", "v2"}
+	if !v.IsValid() {
+		return fmt.Sprintf("TokenEndpointVersion(%d)", v)
+	}
+	return versions[v-1]
+}
+
+func (v TokenEndpointVersion) IsValid() bool {
+	switch v {
+	case TokenEndpointV1, TokenEndpointV2:
+		return true
+	}
+	return false
+}
+
+// ParseTokenEndpointVersion converts a version name (v1 or v2) into a TokenEndpointVersion.
+func ParseTokenEndpointVersion(name string) (TokenEndpointVersion, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "v1", "1", "1.0":
+		return TokenEndpointV1, nil
+	case "v2", "2", "2.0":
+		return TokenEndpointV2, nil
+	}
+	return 0, fmt.Errorf("%s is not a valid token endpoint version, expected v1 or v2", name)
+}
+
 // TwinConfiguration defines properties required for connecting to an Azure Digital
 // Twin instance.
 type TwinConfiguration struct {
@@ -34,36 +80,81 @@ type TwinConfiguration struct {
 
 	// AuthorityUrl defines the base url for obtaining an access token (e.g. https://login.microsoftonline.com)
 	AuthorityUrl url.URL
+
+	// RetryPolicy defines how throttled or failed requests to the authority and the Azure Digital
+	// Twin instance are retried. If nil then retry.DefaultPolicy is used.
+	RetryPolicy *retry.Policy
+
+	// TokenEndpointVersion selects the v1 or v2.0 token endpoint. If not set then the v1
+	// endpoint is used.
+	TokenEndpointVersion TokenEndpointVersion
+
+	// Scope of the AccessToken when it is retrieved from the v2.0 endpoint. If empty then the
+	// ResourceId with the "/.default" suffix is used.
+	Scope string
+
+	// Cloud which the twin instance and the authority belong to. If the HostSuffix is empty then
+	// the URL is not checked against the cloud.
+	Cloud Cloud
+
+	// Logger receives the log entries of the token requests and of any Client created from the
+	// configuration. If nil then nothing is logged.
+	Logger logging.Logger
+
+	// HTTPClient sends the token requests and the requests of any Client created from the
+	// configuration. If nil then pipeline.DefaultHTTPClient is used, which is shared so that
+	// connections are reused.
+	HTTPClient *http.Client
 }
 
-// NewTwinConfiguration creates a new instance of TwinConfiguration
-func NewTwinConfiguration() *TwinConfiguration {
-	err := gotenv.Load()
-	if err != nil {
-		log.Printf("Unable to load environment variables: %v", err)
+// CheckCloud returns an error if the URL is not for an Azure Digital Twin instance in the
+// configured Cloud, which usually means the wrong cloud has been selected.
+func (tc *TwinConfiguration) CheckCloud() error {
+	if tc.Cloud.MatchesHost(tc.URL) {
+		return nil
 	}
+	return fmt.Errorf("url host %s does not match the %s cloud, expected a host ending in .%s", tc.URL.Hostname(), tc.Cloud.Name, strings.TrimPrefix(tc.Cloud.HostSuffix, "."))
+}
 
-	twinUrl, err := url.Parse(getEnvironmentValue("TWIN_URL"))
-	if err != nil {
-		log.Fatal("Invalid Twin URL in environment variable TWIN_URL")
+// GetTokenEndpointVersion returns the configured token endpoint version, or TokenEndpointV1 if
+// one has not been set.
+func (tc *TwinConfiguration) GetTokenEndpointVersion() TokenEndpointVersion {
+	if !tc.TokenEndpointVersion.IsValid() {
+		return TokenEndpointV1
 	}
+	return tc.TokenEndpointVersion
+}
+
+// GetLogger returns the configured logger, or logging.Discard if one has not been set.
+func (tc *TwinConfiguration) GetLogger() logging.Logger {
+	return logging.OrDiscard(tc.Logger)
+}
 
-	authority, _ := url.Parse(AuthorityUrl)
+// GetHTTPClient returns the configured HTTP client, or pipeline.DefaultHTTPClient if one has
+// not been set.
+func (tc *TwinConfiguration) GetHTTPClient() *http.Client {
+	if tc.HTTPClient == nil {
+		return pipeline.DefaultHTTPClient
+	}
+	return tc.HTTPClient
+}
 
-	return &TwinConfiguration{
-		*twinUrl,
-		getEnvironmentValue("TWIN_CLIENT_ID"),
-		getEnvironmentValue("TWIN_CLIENT_SECRET"),
-		getEnvironmentValue("TWIN_TENANT_ID"),
-		ResourceId,
-		*authority,
+// GetRetryPolicy returns the configured retry policy, or the default policy if one has not
+// been set.
+func (tc *TwinConfiguration) GetRetryPolicy() *retry.Policy {
+	if tc.RetryPolicy == nil {
+		return retry.DefaultPolicy()
 	}
+	return tc.RetryPolicy
 }
 
-func getEnvironmentValue(key string) string {
-	value := os.Getenv(key)
-	if value == "" {
-		log.Fatalf("%s not set in environment variables", key)
+// NewTwinConfiguration creates a new instance of TwinConfiguration from the environment and any
+// .env file, exiting the program if the configuration is invalid. Long-running services should
+// use LoadTwinConfiguration instead, which returns the error.
+func NewTwinConfiguration() *TwinConfiguration {
+	configuration, err := LoadTwinConfiguration(LoadOptions{})
+	if err != nil {
+		log.Fatal(err)
 	}
-	return value
+	return configuration
 }
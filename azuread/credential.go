@@ -0,0 +1,203 @@
+package azuread
+
+import (
+	"azure-adt-example/logging"
+	"azure-adt-example/pipeline"
+	"azure-adt-example/retry"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// TokenCredential defines a source of access tokens for the Azure Digital Twin resource.
+type TokenCredential interface {
+	// GetToken acquires a new access token. The request is aborted if the context is cancelled.
+	GetToken(ctx context.Context) (*AccessToken, error)
+}
+
+// CacheKeyer is implemented by credentials which can identify the tokens they acquire, such as
+// by the tenant, client and resource they are requested for. CacheKey uses it so that tokens for
+// different identities are never cached together.
+type CacheKeyer interface {
+	// CacheKey returns a value which is the same for every credential acquiring the same tokens.
+	CacheKey() string
+}
+
+// Interceptable is implemented by credentials which request tokens over HTTP. WithInterceptors
+// returns a copy of the credential whose token requests pass through the interceptors, leaving
+// the original unchanged. A digitaltwin.Client uses it so that its interceptors see the token
+// requests of the credential, however it was created.
+type Interceptable interface {
+	WithInterceptors(interceptors ...pipeline.Interceptor) TokenCredential
+}
+
+// interceptedClient returns a copy of the HTTP client, or of pipeline.DefaultHTTPClient if it is
+// nil, whose requests pass through the interceptors.
+func interceptedClient(client *http.Client, interceptors []pipeline.Interceptor) *http.Client {
+	if client == nil {
+		client = pipeline.DefaultHTTPClient
+	}
+
+	intercepted := *client
+	intercepted.Transport = pipeline.Transport(client.Transport, interceptors...)
+	return &intercepted
+}
+
+// ClientSecretCredential acquires tokens for a service principal using its client secret.
+type ClientSecretCredential struct {
+	TenantId     string
+	ClientId     string
+	ClientSecret string
+	ResourceId   string
+	AuthorityUrl url.URL
+	RetryPolicy  *retry.Policy
+	Logger       logging.Logger
+	HTTPClient   *http.Client
+
+	// TokenEndpointVersion selects the v1 or v2.0 token endpoint, defaulting to v1.
+	TokenEndpointVersion TokenEndpointVersion
+
+	// Scope requested from the v2.0 endpoint. If empty then the default scope of the ResourceId is used.
+	Scope string
+}
+
+// NewClientSecretCredential creates a ClientSecretCredential from the service principal
+// defined in the configuration.
+func NewClientSecretCredential(configuration *TwinConfiguration) *ClientSecretCredential {
+	return &ClientSecretCredential{
+		TenantId:     configuration.TenantId,
+		ClientId:     configuration.ClientId,
+		ClientSecret: configuration.ClientSecret,
+		ResourceId:   configuration.ResourceId,
+		AuthorityUrl: configuration.AuthorityUrl,
+		RetryPolicy:  configuration.GetRetryPolicy(),
+		Logger:       configuration.GetLogger(),
+		HTTPClient:   configuration.HTTPClient,
+
+		TokenEndpointVersion: configuration.GetTokenEndpointVersion(),
+		Scope:                configuration.Scope,
+	}
+}
+
+func (c *ClientSecretCredential) GetToken(ctx context.Context) (*AccessToken, error) {
+	data := url.Values{}
+	data.Add("client_id", c.ClientId)
+	data.Add("client_secret", c.ClientSecret)
+
+	target := tokenTarget{c.AuthorityUrl, c.TenantId, c.TokenEndpointVersion, c.ResourceId, c.Scope}
+	return requestClientCredentialsToken(ctx, c.HTTPClient, c.RetryPolicy, c.Logger, target, data)
+}
+
+func (c *ClientSecretCredential) CacheKey() string {
+	return tokenTarget{c.AuthorityUrl, c.TenantId, c.TokenEndpointVersion, c.ResourceId, c.Scope}.cacheKey(c.ClientId)
+}
+
+func (c *ClientSecretCredential) WithInterceptors(interceptors ...pipeline.Interceptor) TokenCredential {
+	intercepted := *c
+	intercepted.HTTPClient = interceptedClient(c.HTTPClient, interceptors)
+	return &intercepted
+}
+
+// ChainedCredential tries each of its credentials in order, returning the first token
+// acquired. This allows the same code to run locally using the Azure CLI, and in Azure
+// using a managed identity.
+type ChainedCredential struct {
+	Credentials []TokenCredential
+}
+
+// NewChainedCredential creates a ChainedCredential which tries the credentials in the order given.
+func NewChainedCredential(credentials ...TokenCredential) *ChainedCredential {
+	return &ChainedCredential{Credentials: credentials}
+}
+
+func (c *ChainedCredential) GetToken(ctx context.Context) (*AccessToken, error) {
+	if len(c.Credentials) == 0 {
+		return nil, fmt.Errorf("no credentials have been added to the chain")
+	}
+
+	chainError := &ChainedCredentialError{Errors: make([]error, 0, len(c.Credentials))}
+	for _, credential := range c.Credentials {
+		token, err := credential.GetToken(ctx)
+		if err == nil {
+			return token, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("unable to obtain access token: %w", ctx.Err())
+		}
+
+		chainError.Errors = append(chainError.Errors, fmt.Errorf("%T: %w", credential, err))
+	}
+
+	return nil, chainError
+}
+
+// ChainedCredentialError is returned when none of the credentials in a ChainedCredential was able
+// to obtain a token. It holds the error of each credential in order, and errors.Is and errors.As
+// match any of them, such as an *AuthError returned by one of the credentials.
+type ChainedCredentialError struct {
+	Errors []error
+}
+
+func (e *ChainedCredentialError) Error() string {
+	failures := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		failures[i] = err.Error()
+	}
+	return fmt.Sprintf("no credential was able to obtain an access token:\n\t%s", strings.Join(failures, "\n\t"))
+}
+
+// Unwrap returns the error of each credential.
+func (e *ChainedCredentialError) Unwrap() []error {
+	return e.Errors
+}
+
+// Is reports whether the error of any credential matches the target.
+func (e *ChainedCredentialError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first credential error which matches the target, setting target to it.
+func (e *ChainedCredentialError) As(target any) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// CacheKey combines the keys of the credentials in the chain. Credentials which do not
+// implement CacheKeyer are identified by their address.
+func (c *ChainedCredential) CacheKey() string {
+	keys := make([]string, len(c.Credentials))
+	for i, credential := range c.Credentials {
+		if keyer, ok := credential.(CacheKeyer); ok {
+			keys[i] = fmt.Sprintf("%T(%s)", credential, keyer.CacheKey())
+		} else {
+			keys[i] = fmt.Sprintf("%T(%p)", credential, credential)
+		}
+	}
+	return strings.Join(keys, ",")
+}
+
+// WithInterceptors returns a chain in which each credential that is Interceptable is replaced
+// by a copy whose token requests pass through the interceptors.
+func (c *ChainedCredential) WithInterceptors(interceptors ...pipeline.Interceptor) TokenCredential {
+	credentials := make([]TokenCredential, len(c.Credentials))
+	for i, credential := range c.Credentials {
+		if interceptable, ok := credential.(Interceptable); ok {
+			credential = interceptable.WithInterceptors(interceptors...)
+		}
+		credentials[i] = credential
+	}
+	return &ChainedCredential{Credentials: credentials}
+}
@@ -0,0 +1,379 @@
+package azuread
+
+import (
+	"azure-adt-example/logging"
+	"azure-adt-example/retry"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/subosito/gotenv"
+	"gopkg.in/yaml.v3"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultEnvPrefix is the prefix of the environment variables read by LoadTwinConfiguration.
+const DefaultEnvPrefix = "TWIN_"
+
+// ConfigurationValues defines the values which make up a TwinConfiguration, as read from a
+// configuration file, the environment or set explicitly. Empty values are not set.
+type ConfigurationValues struct {
+	URL          string `json:"url,omitempty" yaml:"url,omitempty"`
+	ClientId     string `json:"clientId,omitempty" yaml:"clientId,omitempty"`
+	ClientSecret string `json:"clientSecret,omitempty" yaml:"clientSecret,omitempty"`
+	TenantId     string `json:"tenantId,omitempty" yaml:"tenantId,omitempty"`
+	ResourceId   string `json:"resourceId,omitempty" yaml:"resourceId,omitempty"`
+	AuthorityUrl string `json:"authorityUrl,omitempty" yaml:"authorityUrl,omitempty"`
+	TokenVersion string `json:"tokenVersion,omitempty" yaml:"tokenVersion,omitempty"`
+	Scope        string `json:"scope,omitempty" yaml:"scope,omitempty"`
+
+	// Cloud selects the Azure cloud which provides the defaults for the authority, resource and
+	// scope. Either public (the default), usgovernment, china or custom.
+	Cloud      string `json:"cloud,omitempty" yaml:"cloud,omitempty"`
+	HostSuffix string `json:"hostSuffix,omitempty" yaml:"hostSuffix,omitempty"`
+}
+
+// configurationFile defines the contents of a JSON or YAML configuration file. The top level
+// values apply to every profile, and are overridden by the values of the selected profile.
+type configurationFile struct {
+	ConfigurationValues `yaml:",inline"`
+	Profiles            map[string]ConfigurationValues `json:"profiles,omitempty" yaml:"profiles,omitempty"`
+}
+
+// LoadOptions defines where LoadTwinConfiguration reads the configuration from.
+type LoadOptions struct {
+	// File is the path of a JSON (.json) or YAML (.yaml, .yml) configuration file. If empty then
+	// the file named by the CONFIG_FILE environment variable, with the prefix, is used if set.
+	File string
+
+	// Profile selects the named profile from the configuration file. If empty then the PROFILE
+	// environment variable, with the prefix, is used if set.
+	Profile string
+
+	// EnvFiles are loaded into the environment using gotenv, without replacing variables which
+	// are already set. If nil then ".env" is loaded if it exists.
+	EnvFiles []string
+
+	// EnvPrefix is the prefix of the environment variables to read, such as TWIN_URL. If empty
+	// then DefaultEnvPrefix is used.
+	EnvPrefix string
+
+	// IdentityOptional allows the client id, tenant id and client secret to be missing, for use
+	// with credentials which do not need them such as ManagedIdentityCredential.
+	IdentityOptional bool
+
+	// StrictCloud reports a URL which does not match the selected cloud as a problem, rather than
+	// logging a warning.
+	StrictCloud bool
+
+	// Logger is set on the TwinConfiguration, and receives any warnings about the configuration.
+	// If nil then the warnings are written to the standard logger and nothing else is logged.
+	Logger logging.Logger
+
+	// Values set explicitly, which take precedence over every other source.
+	Values ConfigurationValues
+}
+
+// ConfigurationError is returned when the configuration is invalid, and lists every problem
+// found rather than only the first.
+type ConfigurationError struct {
+	Problems []string
+}
+
+func (e *ConfigurationError) Error() string {
+	return fmt.Sprintf("invalid configuration:\n\t- %s", strings.Join(e.Problems, "\n\t- "))
+}
+
+// LoadTwinConfiguration creates a TwinConfiguration from layered sources. Each source replaces
+// the values of the sources before it:
+//
+//  1. The defaults of the selected cloud, which is the public Azure cloud unless another is chosen.
+//  2. The configuration file, followed by the selected profile within it.
+//  3. The standard AZURE_CLIENT_ID, AZURE_TENANT_ID, AZURE_CLIENT_SECRET and AZURE_AUTHORITY_HOST
+//     environment variables.
+//  4. The environment variables with the prefix, such as TWIN_URL and TWIN_CLIENT_ID.
+//  5. The explicitly set Values.
+//
+// The environment includes any variables loaded from the .env files. A ConfigurationError is
+// returned listing every missing or invalid value.
+func LoadTwinConfiguration(options LoadOptions) (*TwinConfiguration, error) {
+	prefix := options.EnvPrefix
+	if prefix == "" {
+		prefix = DefaultEnvPrefix
+	}
+
+	if err := loadEnvFiles(options.EnvFiles); err != nil {
+		return nil, err
+	}
+
+	values := ConfigurationValues{}
+
+	file := firstNonEmpty(options.File, os.Getenv(prefix+"CONFIG_FILE"))
+	profile := firstNonEmpty(options.Profile, os.Getenv(prefix+"PROFILE"))
+	if file != "" {
+		fileValues, err := readConfigurationFile(file, profile)
+		if err != nil {
+			return nil, err
+		}
+		values.merge(fileValues)
+	} else if profile != "" {
+		return nil, fmt.Errorf("profile %s was selected but no configuration file was given", profile)
+	}
+
+	values.merge(ConfigurationValues{
+		ClientId:     os.Getenv("AZURE_CLIENT_ID"),
+		ClientSecret: os.Getenv("AZURE_CLIENT_SECRET"),
+		TenantId:     os.Getenv("AZURE_TENANT_ID"),
+		AuthorityUrl: os.Getenv("AZURE_AUTHORITY_HOST"),
+	})
+	values.merge(readEnvironmentValues(prefix))
+	values.merge(options.Values)
+
+	return values.build(prefix, options)
+}
+
+// merge replaces the values with any which are set in other.
+func (v *ConfigurationValues) merge(other ConfigurationValues) {
+	set := func(target *string, value string) {
+		if value != "" {
+			*target = value
+		}
+	}
+
+	set(&v.URL, other.URL)
+	set(&v.ClientId, other.ClientId)
+	set(&v.ClientSecret, other.ClientSecret)
+	set(&v.TenantId, other.TenantId)
+	set(&v.ResourceId, other.ResourceId)
+	set(&v.AuthorityUrl, other.AuthorityUrl)
+	set(&v.TokenVersion, other.TokenVersion)
+	set(&v.Scope, other.Scope)
+	set(&v.Cloud, other.Cloud)
+	set(&v.HostSuffix, other.HostSuffix)
+}
+
+// build validates the values and converts them into a TwinConfiguration, using the selected
+// cloud for any values which have not been set. The prefix is used to name the environment
+// variable for each problem reported.
+func (v *ConfigurationValues) build(prefix string, options LoadOptions) (*TwinConfiguration, error) {
+	var problems []string
+
+	cloud, cloudErr := v.applyCloud()
+	if cloudErr != nil {
+		problems = append(problems, cloudErr.Error())
+	}
+
+	configuration := &TwinConfiguration{
+		ClientId:     v.ClientId,
+		ClientSecret: v.ClientSecret,
+		TenantId:     v.TenantId,
+		ResourceId:   v.ResourceId,
+		RetryPolicy:  retry.DefaultPolicy(),
+		Scope:        v.Scope,
+		Cloud:        cloud,
+		Logger:       options.Logger,
+	}
+
+	if v.URL == "" {
+		problems = append(problems, fmt.Sprintf("url is required (%sURL)", prefix))
+	} else if twinUrl, err := parseAbsoluteUrl(v.URL); err != nil {
+		problems = append(problems, fmt.Sprintf("url %s is not valid: %v", v.URL, err))
+	} else {
+		configuration.URL = *twinUrl
+	}
+
+	if v.AuthorityUrl == "" {
+		if cloudErr == nil {
+			problems = append(problems, fmt.Sprintf("authorityUrl is required for a %s cloud (%sAUTHORITY_URL)", customCloud, prefix))
+		}
+	} else if authority, err := parseAbsoluteUrl(v.AuthorityUrl); err != nil {
+		problems = append(problems, fmt.Sprintf("authorityUrl %s is not valid: %v", v.AuthorityUrl, err))
+	} else {
+		configuration.AuthorityUrl = *authority
+	}
+
+	if !options.IdentityOptional {
+		required := []struct{ name, variable, value string }{
+			{"clientId", "CLIENT_ID", v.ClientId},
+			{"clientSecret", "CLIENT_SECRET", v.ClientSecret},
+			{"tenantId", "TENANT_ID", v.TenantId},
+		}
+		for _, r := range required {
+			if r.value == "" {
+				problems = append(problems, fmt.Sprintf("%s is required (%s%s or AZURE_%s)", r.name, prefix, r.variable, r.variable))
+			}
+		}
+	}
+
+	if v.ResourceId == "" && cloudErr == nil {
+		problems = append(problems, fmt.Sprintf("resourceId is required for a %s cloud (%sRESOURCE_ID)", customCloud, prefix))
+	}
+
+	configuration.TokenEndpointVersion = TokenEndpointV1
+	if v.TokenVersion != "" {
+		version, err := ParseTokenEndpointVersion(v.TokenVersion)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+		configuration.TokenEndpointVersion = version
+	}
+
+	if configuration.URL.Host != "" {
+		if err := configuration.CheckCloud(); err != nil {
+			if options.StrictCloud {
+				problems = append(problems, err.Error())
+			} else {
+				warnings := options.Logger
+				if warnings == nil {
+					warnings = logging.NewStdLogger(nil, logging.LevelWarn)
+				}
+				warnings.Log(logging.LevelWarn, "url does not match the selected cloud", logging.F(logging.FieldError, err))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return nil, &ConfigurationError{Problems: problems}
+	}
+
+	return configuration, nil
+}
+
+// applyCloud sets any values which have not been set to those of the selected cloud, returning
+// the cloud with the values which will be used.
+func (v *ConfigurationValues) applyCloud() (Cloud, error) {
+	cloud := Cloud{Name: customCloud}
+
+	var err error
+	if v.Cloud == "" {
+		cloud = AzurePublic
+	} else if !strings.EqualFold(v.Cloud, customCloud) {
+		cloud, err = ParseCloud(v.Cloud)
+	}
+
+	if v.AuthorityUrl == "" {
+		v.AuthorityUrl = cloud.AuthorityUrl
+	}
+	if v.ResourceId == "" {
+		v.ResourceId = cloud.ResourceId
+	}
+	if v.Scope == "" {
+		v.Scope = cloud.Scope
+	}
+	if v.HostSuffix == "" {
+		v.HostSuffix = cloud.HostSuffix
+	}
+
+	return Cloud{Name: cloud.Name, AuthorityUrl: v.AuthorityUrl, ResourceId: v.ResourceId, Scope: v.Scope, HostSuffix: v.HostSuffix}, err
+}
+
+// readEnvironmentValues reads the values from the environment variables with the prefix.
+func readEnvironmentValues(prefix string) ConfigurationValues {
+	return ConfigurationValues{
+		URL:          os.Getenv(prefix + "URL"),
+		ClientId:     os.Getenv(prefix + "CLIENT_ID"),
+		ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+		TenantId:     os.Getenv(prefix + "TENANT_ID"),
+		ResourceId:   os.Getenv(prefix + "RESOURCE_ID"),
+		AuthorityUrl: os.Getenv(prefix + "AUTHORITY_URL"),
+		TokenVersion: os.Getenv(prefix + "TOKEN_VERSION"),
+		Scope:        os.Getenv(prefix + "SCOPE"),
+		Cloud:        os.Getenv(prefix + "CLOUD"),
+		HostSuffix:   os.Getenv(prefix + "HOST_SUFFIX"),
+	}
+}
+
+// loadEnvFiles loads the files into the environment. If files is nil then ".env" is loaded if
+// it exists, otherwise every file must exist.
+func loadEnvFiles(files []string) error {
+	if files == nil {
+		if _, err := os.Stat(".env"); errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		files = []string{".env"}
+	}
+
+	for _, file := range files {
+		if err := gotenv.Load(file); err != nil {
+			return fmt.Errorf("unable to load environment file %s: %v", file, err)
+		}
+	}
+
+	return nil
+}
+
+// readConfigurationFile reads a JSON or YAML configuration file, returning the top level values
+// replaced by those of the profile, if one is given.
+func readConfigurationFile(path string, profile string) (ConfigurationValues, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ConfigurationValues{}, fmt.Errorf("unable to read configuration file: %v", err)
+	}
+
+	var file configurationFile
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&file)
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(content))
+		decoder.KnownFields(true)
+		if err = decoder.Decode(&file); errors.Is(err, io.EOF) {
+			err = nil
+		}
+	default:
+		return ConfigurationValues{}, fmt.Errorf("configuration file %s must be JSON (.json) or YAML (.yaml, .yml)", path)
+	}
+	if err != nil {
+		return ConfigurationValues{}, fmt.Errorf("unable to parse configuration file %s: %v", path, err)
+	}
+
+	values := file.ConfigurationValues
+	if profile != "" {
+		profileValues, ok := file.Profiles[profile]
+		if !ok {
+			return ConfigurationValues{}, fmt.Errorf("profile %s is not defined in %s, available profiles are: %s", profile, path, strings.Join(profileNames(file.Profiles), ", "))
+		}
+		values.merge(profileValues)
+	}
+
+	return values, nil
+}
+
+// profileNames returns the names of the profiles in alphabetical order.
+func profileNames(profiles map[string]ConfigurationValues) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseAbsoluteUrl parses the value, which must be an absolute URL with a host.
+func parseAbsoluteUrl(value string) (*url.URL, error) {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("an absolute URL is required")
+	}
+	return parsed, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,248 @@
+package azuread
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// clearEnvironment unsets every environment variable read by LoadTwinConfiguration for the
+// duration of the test.
+func clearEnvironment(t *testing.T) {
+	names := []string{"AZURE_CLIENT_ID", "AZURE_CLIENT_SECRET", "AZURE_TENANT_ID", "AZURE_AUTHORITY_HOST"}
+	for _, name := range []string{"URL", "CLIENT_ID", "CLIENT_SECRET", "TENANT_ID", "RESOURCE_ID", "AUTHORITY_URL", "TOKEN_VERSION", "SCOPE", "CLOUD", "HOST_SUFFIX", "CONFIG_FILE", "PROFILE"} {
+		names = append(names, "TWIN_"+name, "APP_"+name)
+	}
+
+	for _, name := range names {
+		t.Setenv(name, "")
+		_ = os.Unsetenv(name)
+	}
+}
+
+func writeFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("Unable to write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadTwinConfiguration_Environment(t *testing.T) {
+	clearEnvironment(t)
+	t.Setenv("TWIN_URL", "https://example.api.weu.digitaltwins.azure.net")
+	t.Setenv("TWIN_CLIENT_ID", "client1")
+	t.Setenv("AZURE_CLIENT_ID", "client2")
+	t.Setenv("AZURE_CLIENT_SECRET", "secret2")
+	t.Setenv("AZURE_TENANT_ID", "tenant2")
+	t.Setenv("TWIN_TOKEN_VERSION", "v2")
+
+	configuration, err := LoadTwinConfiguration(LoadOptions{EnvFiles: []string{}})
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if configuration.URL.Host != "example.api.weu.digitaltwins.azure.net" || configuration.ClientId != "client1" || configuration.ClientSecret != "secret2" || configuration.TenantId != "tenant2" {
+		t.Errorf("Unexpected configuration %+v", configuration)
+	}
+
+	if configuration.ResourceId != ResourceId || configuration.AuthorityUrl.String() != AuthorityUrl || configuration.TokenEndpointVersion != TokenEndpointV2 || configuration.RetryPolicy == nil {
+		t.Errorf("Unexpected defaults %+v", configuration)
+	}
+}
+
+func TestLoadTwinConfiguration_Prefix(t *testing.T) {
+	clearEnvironment(t)
+	t.Setenv("APP_URL", "https://example.api.weu.digitaltwins.azure.net")
+	t.Setenv("TWIN_URL", "https://ignored.api.weu.digitaltwins.azure.net")
+
+	configuration, err := LoadTwinConfiguration(LoadOptions{EnvFiles: []string{}, EnvPrefix: "APP_", IdentityOptional: true})
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if configuration.URL.Host != "example.api.weu.digitaltwins.azure.net" {
+		t.Errorf("Expected the prefixed URL, but got %s", configuration.URL.String())
+	}
+}
+
+func TestLoadTwinConfiguration_JsonProfile(t *testing.T) {
+	clearEnvironment(t)
+	file := writeFile(t, "twin.json", `{
+		"clientId": "client1",
+		"clientSecret": "secret1",
+		"tenantId": "tenant1",
+		"profiles": {
+			"dev": { "url": "https://dev.api.weu.digitaltwins.azure.net" },
+			"prod": { "url": "https://prod.api.weu.digitaltwins.azure.net", "clientId": "client2" }
+		}
+	}`)
+	t.Setenv("TWIN_CLIENT_SECRET", "secret3")
+
+	configuration, err := LoadTwinConfiguration(LoadOptions{EnvFiles: []string{}, File: file, Profile: "prod"})
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if configuration.URL.Host != "prod.api.weu.digitaltwins.azure.net" || configuration.ClientId != "client2" || configuration.TenantId != "tenant1" || configuration.ClientSecret != "secret3" {
+		t.Errorf("Unexpected configuration %+v", configuration)
+	}
+
+	_, err = LoadTwinConfiguration(LoadOptions{EnvFiles: []string{}, File: file, Profile: "test"})
+	if err == nil || !strings.Contains(err.Error(), "available profiles are: dev, prod") {
+		t.Errorf("Expected unknown profile error, but got %v", err)
+	}
+}
+
+func TestLoadTwinConfiguration_YamlAndEnvFile(t *testing.T) {
+	clearEnvironment(t)
+	file := writeFile(t, "twin.yaml", `# Shared values
+tenantId: "tenant1"
+clientId: client1 # inline comment
+profiles:
+  dev:
+    url: https://dev.api.weu.digitaltwins.azure.net
+  prod:
+    url: 'https://prod.api.weu.digitaltwins.azure.net'
+`)
+	envFile := writeFile(t, "test.env", "TWIN_CLIENT_SECRET=secret1\nTWIN_PROFILE=dev\n")
+	t.Cleanup(func() {
+		_ = os.Unsetenv("TWIN_CLIENT_SECRET")
+		_ = os.Unsetenv("TWIN_PROFILE")
+	})
+
+	configuration, err := LoadTwinConfiguration(LoadOptions{EnvFiles: []string{envFile}, File: file})
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if configuration.URL.Host != "dev.api.weu.digitaltwins.azure.net" || configuration.ClientId != "client1" || configuration.TenantId != "tenant1" || configuration.ClientSecret != "secret1" {
+		t.Errorf("Unexpected configuration %+v", configuration)
+	}
+}
+
+func TestLoadTwinConfiguration_ExplicitValues(t *testing.T) {
+	clearEnvironment(t)
+	t.Setenv("TWIN_URL", "https://env.api.weu.digitaltwins.azure.net")
+
+	configuration, err := LoadTwinConfiguration(LoadOptions{
+		EnvFiles: []string{},
+		Values:   ConfigurationValues{URL: "https://explicit.api.weu.digitaltwins.azure.net", ClientId: "client1", ClientSecret: "secret1", TenantId: "tenant1"},
+	})
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if configuration.URL.Host != "explicit.api.weu.digitaltwins.azure.net" {
+		t.Errorf("Expected the explicit URL, but got %s", configuration.URL.String())
+	}
+}
+
+func TestLoadTwinConfiguration_ReportsAllProblems(t *testing.T) {
+	clearEnvironment(t)
+	t.Setenv("TWIN_URL", "not a url")
+	t.Setenv("TWIN_TOKEN_VERSION", "v3")
+
+	_, err := LoadTwinConfiguration(LoadOptions{EnvFiles: []string{}})
+
+	var configurationError *ConfigurationError
+	if !errors.As(err, &configurationError) {
+		t.Fatalf("Expected a ConfigurationError, but got %v", err)
+	}
+
+	expected := []string{
+		"url not a url is not valid: an absolute URL is required",
+		"clientId is required (TWIN_CLIENT_ID or AZURE_CLIENT_ID)",
+		"clientSecret is required (TWIN_CLIENT_SECRET or AZURE_CLIENT_SECRET)",
+		"tenantId is required (TWIN_TENANT_ID or AZURE_TENANT_ID)",
+		"v3 is not a valid token endpoint version, expected v1 or v2",
+	}
+	if !reflect.DeepEqual(configurationError.Problems, expected) {
+		t.Errorf("Unexpected problems\n%s", strings.Join(configurationError.Problems, "\n"))
+	}
+}
+
+func TestLoadTwinConfiguration_FileErrors(t *testing.T) {
+	clearEnvironment(t)
+
+	tests := []struct {
+		name    string
+		options LoadOptions
+		message string
+	}{
+		{"unknown field", LoadOptions{File: writeFile(t, "twin.json", `{ "clientSecrt": "secret1" }`)}, "unknown field"},
+		{"unsupported format", LoadOptions{File: writeFile(t, "twin.toml", "url = 'x'")}, "must be JSON (.json) or YAML"},
+		{"missing file", LoadOptions{File: filepath.Join(t.TempDir(), "missing.json")}, "unable to read configuration file"},
+		{"profile without file", LoadOptions{Profile: "dev"}, "no configuration file was given"},
+		{"missing env file", LoadOptions{EnvFiles: []string{filepath.Join(t.TempDir(), "missing.env")}}, "unable to load environment file"},
+	}
+
+	for _, test := range tests {
+		if test.options.EnvFiles == nil {
+			test.options.EnvFiles = []string{}
+		}
+		_, err := LoadTwinConfiguration(test.options)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("%s: expected error containing '%s', but got %v", test.name, test.message, err)
+		}
+	}
+}
+
+func TestReadConfigurationFile_Yaml(t *testing.T) {
+	file := writeFile(t, "twin.yaml", `---
+clientId: client1 # comment
+profiles:
+  base: &base
+    tenantId: "tenant\x31"
+    tokenVersion: 2
+  dev:
+    <<: *base
+    url: "https://example/#not-a-comment"
+    scope: >-
+      https://example/.default
+      extra
+    clientSecret: 'it''s'
+`)
+
+	values, err := readConfigurationFile(file, "dev")
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	expected := ConfigurationValues{
+		URL:          "https://example/#not-a-comment",
+		ClientId:     "client1",
+		ClientSecret: "it's",
+		TenantId:     "tenant1",
+		TokenVersion: "2",
+		Scope:        "https://example/.default extra",
+	}
+	if values != expected {
+		t.Errorf("Expected %+v, but got %+v", expected, values)
+	}
+}
+
+func TestReadConfigurationFile_YamlInvalid(t *testing.T) {
+	tests := map[string]string{
+		"url: a\nurl: b":        "already defined",
+		"profiles:\n  - dev":    "cannot unmarshal !!seq",
+		"url: [a, b]":           "cannot unmarshal !!seq",
+		"a: 1\n   b: 2":         "mapping values are not allowed",
+		"just text":             "cannot unmarshal !!str",
+		"unknown: value":        "field unknown not found",
+		"a:\n\tb: 1":            "found character that cannot start any token",
+		"url: \"unterminated":   "found unexpected end of stream",
+		"url: *missing":         "unknown anchor",
+		"profiles:\n  dev: a\n": "cannot unmarshal !!str",
+	}
+
+	for content, message := range tests {
+		_, err := readConfigurationFile(writeFile(t, "twin.yaml", content), "")
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("Expected error containing '%s' for %q, but got %v", message, content, err)
+		}
+	}
+}
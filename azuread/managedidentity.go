@@ -0,0 +1,156 @@
+package azuread
+
+import (
+	"azure-adt-example/logging"
+	"azure-adt-example/pipeline"
+	"azure-adt-example/retry"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// imdsEndpoint is the token endpoint of the Azure Instance Metadata Service, available to
+	// virtual machines, scale sets and container instances.
+	imdsEndpoint   = "http://169.254.169.254/metadata/identity/oauth2/token"
+	imdsApiVersion = "2018-02-01"
+
+	// appServiceApiVersion is the version of the token endpoint which App Service and Azure
+	// Functions expose through the IDENTITY_ENDPOINT environment variable.
+	appServiceApiVersion = "2019-08-01"
+
+	// DefaultIMDSProbeTimeout is how long the first request to the Instance Metadata Service
+	// waits for a response when ProbeTimeout is not set.
+	DefaultIMDSProbeTimeout = time.Second
+)
+
+// ManagedIdentityCredential acquires tokens for the managed identity of the Azure resource the
+// code is running on.
+type ManagedIdentityCredential struct {
+	// ClientId of a user-assigned identity. If empty then the system-assigned identity is used.
+	ClientId string
+
+	ResourceId string
+
+	// Endpoint of the local token service. This can be changed to point at a local stand-in
+	// when developing outside Azure.
+	Endpoint string
+
+	// IdentityHeader is the secret sent to the App Service token endpoint. If empty then the
+	// Endpoint is treated as the Instance Metadata Service.
+	IdentityHeader string
+
+	// ProbeTimeout limits the requests to the Instance Metadata Service until it first responds.
+	// These requests are not retried, so that a ChainedCredential moves on quickly when the code
+	// is not running in Azure. If zero then DefaultIMDSProbeTimeout is used.
+	ProbeTimeout time.Duration
+
+	RetryPolicy *retry.Policy
+	Logger      logging.Logger
+	HTTPClient  *http.Client
+
+	// imdsAvailable is set once the Instance Metadata Service has responded, after which
+	// requests use the RetryPolicy without the probe timeout.
+	imdsAvailable int32
+}
+
+// NewManagedIdentityCredential creates a ManagedIdentityCredential for the resource in the
+// configuration. The App Service endpoint is used if the IDENTITY_ENDPOINT and IDENTITY_HEADER
+// environment variables are set, otherwise the Instance Metadata Service is used. The clientId
+// selects a user-assigned identity and may be empty.
+func NewManagedIdentityCredential(configuration *TwinConfiguration, clientId string) *ManagedIdentityCredential {
+	credential := &ManagedIdentityCredential{
+		ClientId:    clientId,
+		ResourceId:  configuration.ResourceId,
+		Endpoint:    imdsEndpoint,
+		RetryPolicy: configuration.GetRetryPolicy(),
+		Logger:      configuration.GetLogger(),
+		HTTPClient:  configuration.HTTPClient,
+	}
+
+	endpoint, header := os.Getenv("IDENTITY_ENDPOINT"), os.Getenv("IDENTITY_HEADER")
+	if endpoint != "" && header != "" {
+		credential.Endpoint = endpoint
+		credential.IdentityHeader = header
+	}
+
+	return credential
+}
+
+func (c *ManagedIdentityCredential) GetToken(ctx context.Context) (*AccessToken, error) {
+	policy := c.RetryPolicy
+	probe := c.IdentityHeader == "" && atomic.LoadInt32(&c.imdsAvailable) == 0
+	if probe {
+		timeout := c.ProbeTimeout
+		if timeout <= 0 {
+			timeout = DefaultIMDSProbeTimeout
+		}
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		policy = nil
+	}
+
+	endpoint, err := url.Parse(c.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid managed identity endpoint %s: %v", c.Endpoint, err)
+	}
+
+	params := url.Values{}
+	params.Set("resource", c.ResourceId)
+	if c.ClientId != "" {
+		params.Set("client_id", c.ClientId)
+	}
+
+	if c.IdentityHeader != "" {
+		params.Set("api-version", appServiceApiVersion)
+	} else {
+		params.Set("api-version", imdsApiVersion)
+	}
+	endpoint.RawQuery = params.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create token request: %v", err)
+	}
+
+	if c.IdentityHeader != "" {
+		req.Header.Set("X-IDENTITY-HEADER", c.IdentityHeader)
+	} else {
+		req.Header.Set("Metadata", "true")
+	}
+
+	token, err := requestToken(c.HTTPClient, policy, c.Logger, req, c.ResourceId)
+
+	// Any response, including an error, shows that the service is there
+	var authError *AuthError
+	if probe && (err == nil || errors.As(err, &authError)) {
+		atomic.StoreInt32(&c.imdsAvailable, 1)
+	}
+
+	return token, err
+}
+
+func (c *ManagedIdentityCredential) CacheKey() string {
+	return fmt.Sprintf("%s|%s|%s", c.Endpoint, c.ClientId, c.ResourceId)
+}
+
+func (c *ManagedIdentityCredential) WithInterceptors(interceptors ...pipeline.Interceptor) TokenCredential {
+	return &ManagedIdentityCredential{
+		ClientId:       c.ClientId,
+		ResourceId:     c.ResourceId,
+		Endpoint:       c.Endpoint,
+		IdentityHeader: c.IdentityHeader,
+		ProbeTimeout:   c.ProbeTimeout,
+		RetryPolicy:    c.RetryPolicy,
+		Logger:         c.Logger,
+		HTTPClient:     interceptedClient(c.HTTPClient, interceptors),
+		imdsAvailable:  atomic.LoadInt32(&c.imdsAvailable),
+	}
+}
@@ -0,0 +1,167 @@
+package azuread
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultRefreshMargin is how long before a token expires that it is refreshed.
+const DefaultRefreshMargin = 5 * time.Minute
+
+// refreshTimeout limits how long a shared token request can take, as it is not cancelled by
+// the callers waiting on it.
+const refreshTimeout = time.Minute
+
+// DefaultTokenCache is the cache shared by every digitaltwin.Client which has not been given
+// its own, so that clients for the same tenant and resource reuse the same token.
+var DefaultTokenCache = NewTokenCache(DefaultRefreshMargin)
+
+// TokenCache holds access tokens so that they are reused until shortly before they expire.
+// Tokens are held against a key, such as one created by CacheKey, so that tokens for several
+// tenants or resources can be held by the same cache. A TokenCache is safe for concurrent use,
+// and concurrent requests for a token which needs refreshing share a single token request.
+type TokenCache struct {
+	// RefreshMargin is how long before a token expires that it is refreshed.
+	RefreshMargin time.Duration
+
+	mu      sync.Mutex
+	tokens  map[string]*AccessToken
+	pending map[string]*tokenRefresh
+
+	// now returns the current time, allowing it to be replaced when testing.
+	now func() time.Time
+}
+
+// tokenRefresh is a token request which is in progress. The done channel is closed once the
+// token or err has been set.
+type tokenRefresh struct {
+	done  chan struct{}
+	token *AccessToken
+	err   error
+}
+
+// NewTokenCache creates a TokenCache which refreshes tokens the margin before they expire.
+func NewTokenCache(refreshMargin time.Duration) *TokenCache {
+	return &TokenCache{
+		RefreshMargin: refreshMargin,
+		tokens:        make(map[string]*AccessToken),
+		pending:       make(map[string]*tokenRefresh),
+		now:           time.Now,
+	}
+}
+
+// CacheKey creates the key under which tokens acquired by the credential for the configuration
+// are cached. Tokens are held separately for each authority, tenant, client, resource (or
+// scope) and type of credential. If the credential implements CacheKeyer then the key is taken
+// from the identity the credential uses rather than from the configuration.
+func CacheKey(configuration *TwinConfiguration, credential TokenCredential) string {
+	if keyer, ok := credential.(CacheKeyer); ok {
+		return fmt.Sprintf("%T|%s", credential, keyer.CacheKey())
+	}
+
+	audience := configuration.ResourceId
+	if configuration.GetTokenEndpointVersion() == TokenEndpointV2 {
+		audience = tokenTarget{resourceId: configuration.ResourceId, scope: configuration.Scope, version: TokenEndpointV2}.audience()
+	}
+
+	return fmt.Sprintf("%T|%s|%s|%s|%s", credential, configuration.AuthorityUrl.String(), configuration.TenantId, configuration.ClientId, audience)
+}
+
+// GetToken returns the token cached against the key, using the credential to acquire a new
+// token if there is none or it is due to be refreshed. If a refresh fails, but the cached
+// token has not yet expired, then the cached token is returned.
+func (c *TokenCache) GetToken(ctx context.Context, key string, credential TokenCredential) (*AccessToken, error) {
+	c.mu.Lock()
+
+	cached := c.tokens[key]
+	if cached != nil && c.isFresh(cached) {
+		c.mu.Unlock()
+		return cached, nil
+	}
+
+	refresh, inProgress := c.pending[key]
+	if !inProgress {
+		refresh = &tokenRefresh{done: make(chan struct{})}
+		c.pending[key] = refresh
+	}
+
+	c.mu.Unlock()
+
+	if !inProgress {
+		go c.refresh(detachedContext{ctx}, key, credential, refresh)
+	}
+
+	select {
+	case <-refresh.done:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("unable to obtain access token: %w", ctx.Err())
+	}
+
+	if refresh.err != nil {
+		if cached != nil && !c.isExpired(cached) {
+			return cached, nil
+		}
+		return nil, refresh.err
+	}
+
+	return refresh.token, nil
+}
+
+// Invalidate removes the token cached against the key, so that the next request acquires a
+// new token. This is useful if the token has been revoked.
+func (c *TokenCache) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.tokens, key)
+}
+
+// refresh acquires a new token using the credential, storing it in the cache and completing
+// the refresh so that any other callers waiting on it receive the same result. The refresh is
+// shared, so it is not cancelled by the caller which started it, only by refreshTimeout.
+func (c *TokenCache) refresh(ctx context.Context, key string, credential TokenCredential, refresh *tokenRefresh) {
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	defer cancel()
+
+	token, err := credential.GetToken(ctx)
+	if err == nil && token == nil {
+		err = fmt.Errorf("%T did not return an access token", credential)
+	}
+
+	c.mu.Lock()
+	if err == nil {
+		c.tokens[key] = token
+	}
+	delete(c.pending, key)
+	c.mu.Unlock()
+
+	refresh.token = token
+	refresh.err = err
+	close(refresh.done)
+}
+
+// isFresh is true if the token is not yet due to be refreshed.
+func (c *TokenCache) isFresh(token *AccessToken) bool {
+	return c.now().Add(c.RefreshMargin).Unix() < token.ExpiresOn
+}
+
+// isExpired is true if the token can no longer be used.
+func (c *TokenCache) isExpired(token *AccessToken) bool {
+	return c.now().Unix() >= token.ExpiresOn
+}
+
+// detachedContext keeps the values of its parent, such as the span being traced, but is never
+// cancelled and has no deadline.
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (d detachedContext) Value(key any) any {
+	return d.parent.Value(key)
+}
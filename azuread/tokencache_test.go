@@ -0,0 +1,221 @@
+package azuread
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingCredential returns a token which expires after the lifetime, counting the number
+// of tokens requested. If release is set then each request waits until it is closed.
+type countingCredential struct {
+	calls    int32
+	lifetime time.Duration
+	release  chan struct{}
+	err      error
+}
+
+func (c *countingCredential) GetToken(ctx context.Context) (*AccessToken, error) {
+	call := atomic.AddInt32(&c.calls, 1)
+	if c.release != nil {
+		<-c.release
+	}
+	if c.err != nil {
+		return nil, c.err
+	}
+	return &AccessToken{AccessToken: string(rune('a' + call - 1)), ExpiresOn: time.Now().Add(c.lifetime).Unix()}, nil
+}
+
+func TestTokenCache_ReusesToken(t *testing.T) {
+	cache := NewTokenCache(time.Minute)
+	credential := &countingCredential{lifetime: time.Hour}
+
+	for i := 0; i < 3; i++ {
+		token, err := cache.GetToken(context.Background(), "key1", credential)
+		if err != nil || token.AccessToken != "a" {
+			t.Fatalf("Expected cached token 'a', but got %v, %v", token, err)
+		}
+	}
+
+	if credential.calls != 1 {
+		t.Errorf("Expected 1 token request, but got %d", credential.calls)
+	}
+}
+
+func TestTokenCache_RefreshesWithinMargin(t *testing.T) {
+	cache := NewTokenCache(10 * time.Minute)
+	credential := &countingCredential{lifetime: 5 * time.Minute}
+
+	_, _ = cache.GetToken(context.Background(), "key1", credential)
+	token, _ := cache.GetToken(context.Background(), "key1", credential)
+
+	if credential.calls != 2 || token.AccessToken != "b" {
+		t.Errorf("Expected the token to be refreshed, but got %d requests and token %s", credential.calls, token.AccessToken)
+	}
+}
+
+func TestTokenCache_KeepsValidTokenIfRefreshFails(t *testing.T) {
+	cache := NewTokenCache(10 * time.Minute)
+	credential := &countingCredential{lifetime: 5 * time.Minute}
+
+	first, _ := cache.GetToken(context.Background(), "key1", credential)
+
+	credential.err = errors.New("authority unavailable")
+	token, err := cache.GetToken(context.Background(), "key1", credential)
+	if err != nil || token != first {
+		t.Errorf("Expected the cached token, but got %v, %v", token, err)
+	}
+
+	cache.now = func() time.Time { return time.Now().Add(time.Hour) }
+	if _, err = cache.GetToken(context.Background(), "key1", credential); err == nil {
+		t.Error("Expected an error once the cached token has expired")
+	}
+}
+
+func TestTokenCache_SingleRefresh(t *testing.T) {
+	cache := NewTokenCache(time.Minute)
+	credential := &countingCredential{lifetime: time.Hour, release: make(chan struct{})}
+
+	var wg sync.WaitGroup
+	tokens := make([]*AccessToken, 10)
+	for i := range tokens {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			tokens[i], _ = cache.GetToken(context.Background(), "key1", credential)
+		}(i)
+	}
+
+	for atomic.LoadInt32(&credential.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(credential.release)
+	wg.Wait()
+
+	if credential.calls != 1 {
+		t.Errorf("Expected concurrent refreshes to share 1 token request, but got %d", credential.calls)
+	}
+
+	for i, token := range tokens {
+		if token == nil || token.AccessToken != "a" {
+			t.Errorf("Caller %d received unexpected token %v", i, token)
+		}
+	}
+}
+
+func TestTokenCache_SeparateKeys(t *testing.T) {
+	cache := NewTokenCache(time.Minute)
+	credential := &countingCredential{lifetime: time.Hour}
+
+	first, _ := cache.GetToken(context.Background(), "key1", credential)
+	second, _ := cache.GetToken(context.Background(), "key2", credential)
+
+	if first.AccessToken == second.AccessToken || credential.calls != 2 {
+		t.Errorf("Expected a token per key, but got %s and %s", first.AccessToken, second.AccessToken)
+	}
+
+	cache.Invalidate("key1")
+	if token, _ := cache.GetToken(context.Background(), "key1", credential); token.AccessToken != "c" {
+		t.Errorf("Expected a new token after invalidating, but got %s", token.AccessToken)
+	}
+}
+
+func TestTokenCache_WaitCancelled(t *testing.T) {
+	cache := NewTokenCache(time.Minute)
+	credential := &countingCredential{lifetime: time.Hour, release: make(chan struct{})}
+	defer close(credential.release)
+
+	go func() { _, _ = cache.GetToken(context.Background(), "key1", credential) }()
+	for atomic.LoadInt32(&credential.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := cache.GetToken(ctx, "key1", credential); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected deadline exceeded, but got %v", err)
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	authority, _ := url.Parse("https://login.microsoftonline.com")
+	configuration := &TwinConfiguration{TenantId: "tenant1", ClientId: "client1", ResourceId: "resource1", AuthorityUrl: *authority}
+
+	v1 := CacheKey(configuration, NewClientSecretCredential(configuration))
+
+	configuration.TokenEndpointVersion = TokenEndpointV2
+	v2 := CacheKey(configuration, NewClientSecretCredential(configuration))
+
+	configuration.TenantId = "tenant2"
+	otherTenant := CacheKey(configuration, NewClientSecretCredential(configuration))
+
+	if v1 == v2 || v2 == otherTenant {
+		t.Errorf("Expected distinct keys, but got %s, %s and %s", v1, v2, otherTenant)
+	}
+}
+
+func TestTokenCache_LeaderCancelled(t *testing.T) {
+	cache := NewTokenCache(time.Minute)
+	credential := &countingCredential{lifetime: time.Hour, release: make(chan struct{})}
+
+	leaderCtx, cancelLeader := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := cache.GetToken(leaderCtx, "key1", credential)
+		leaderErr <- err
+	}()
+	for atomic.LoadInt32(&credential.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	waiter := make(chan *AccessToken, 1)
+	go func() {
+		token, _ := cache.GetToken(context.Background(), "key1", credential)
+		waiter <- token
+	}()
+
+	cancelLeader()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the leader to be cancelled, but got %v", err)
+	}
+
+	close(credential.release)
+	if token := <-waiter; token == nil || token.AccessToken != "a" {
+		t.Errorf("Expected the waiter to receive token 'a', but got %v", token)
+	}
+	if credential.calls != 1 {
+		t.Errorf("Expected 1 token request, but got %d", credential.calls)
+	}
+}
+
+func TestCacheKey_CredentialIdentity(t *testing.T) {
+	authority, _ := url.Parse("https://login.microsoftonline.com")
+	configuration := &TwinConfiguration{TenantId: "tenant1", ClientId: "client1", ResourceId: "resource1", AuthorityUrl: *authority}
+
+	system := CacheKey(configuration, NewManagedIdentityCredential(configuration, ""))
+	userAssigned := CacheKey(configuration, NewManagedIdentityCredential(configuration, "identity1"))
+	otherUserAssigned := CacheKey(configuration, NewManagedIdentityCredential(configuration, "identity2"))
+
+	if system == userAssigned || userAssigned == otherUserAssigned {
+		t.Errorf("Expected a key per managed identity, but got %s, %s and %s", system, userAssigned, otherUserAssigned)
+	}
+
+	workload := NewWorkloadIdentityCredential(&TwinConfiguration{ResourceId: "resource1", AuthorityUrl: *authority})
+	workload.TenantId, workload.ClientId = "tenant2", "client2"
+	otherWorkload := NewWorkloadIdentityCredential(&TwinConfiguration{ResourceId: "resource1", AuthorityUrl: *authority})
+	otherWorkload.TenantId, otherWorkload.ClientId = "tenant2", "client3"
+
+	if CacheKey(configuration, workload) == CacheKey(configuration, otherWorkload) {
+		t.Errorf("Expected a key per workload identity client, but both were %s", CacheKey(configuration, workload))
+	}
+
+	if CacheKey(configuration, NewManagedIdentityCredential(configuration, "identity1")) != userAssigned {
+		t.Error("Expected credentials for the same identity to share a key")
+	}
+}
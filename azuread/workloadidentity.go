@@ -0,0 +1,94 @@
+package azuread
+
+import (
+	"azure-adt-example/logging"
+	"azure-adt-example/pipeline"
+	"azure-adt-example/retry"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// WorkloadIdentityCredential acquires tokens for an application using a federated token, such
+// as the service account token projected into a Kubernetes pod by Azure AD Workload Identity.
+type WorkloadIdentityCredential struct {
+	TenantId string
+	ClientId string
+
+	// TokenFile is the path of the federated token. The file is read for every request as the
+	// token is rotated while the application runs.
+	TokenFile string
+
+	ResourceId   string
+	AuthorityUrl url.URL
+	RetryPolicy  *retry.Policy
+	Logger       logging.Logger
+	HTTPClient   *http.Client
+
+	// TokenEndpointVersion selects the v1 or v2.0 token endpoint, defaulting to v1.
+	TokenEndpointVersion TokenEndpointVersion
+
+	// Scope requested from the v2.0 endpoint. If empty then the default scope of the ResourceId is used.
+	Scope string
+}
+
+// NewWorkloadIdentityCredential creates a WorkloadIdentityCredential for the resource in the
+// configuration. The tenant and client ids are taken from the configuration if set, otherwise
+// from the AZURE_TENANT_ID and AZURE_CLIENT_ID environment variables, and the token file from
+// AZURE_FEDERATED_TOKEN_FILE.
+func NewWorkloadIdentityCredential(configuration *TwinConfiguration) *WorkloadIdentityCredential {
+	credential := &WorkloadIdentityCredential{
+		TenantId:     configuration.TenantId,
+		ClientId:     configuration.ClientId,
+		TokenFile:    os.Getenv("AZURE_FEDERATED_TOKEN_FILE"),
+		ResourceId:   configuration.ResourceId,
+		AuthorityUrl: configuration.AuthorityUrl,
+		RetryPolicy:  configuration.GetRetryPolicy(),
+		Logger:       configuration.GetLogger(),
+		HTTPClient:   configuration.HTTPClient,
+
+		TokenEndpointVersion: configuration.GetTokenEndpointVersion(),
+		Scope:                configuration.Scope,
+	}
+
+	if credential.TenantId == "" {
+		credential.TenantId = os.Getenv("AZURE_TENANT_ID")
+	}
+	if credential.ClientId == "" {
+		credential.ClientId = os.Getenv("AZURE_CLIENT_ID")
+	}
+
+	return credential
+}
+
+func (c *WorkloadIdentityCredential) CacheKey() string {
+	return tokenTarget{c.AuthorityUrl, c.TenantId, c.TokenEndpointVersion, c.ResourceId, c.Scope}.cacheKey(c.ClientId)
+}
+
+func (c *WorkloadIdentityCredential) WithInterceptors(interceptors ...pipeline.Interceptor) TokenCredential {
+	intercepted := *c
+	intercepted.HTTPClient = interceptedClient(c.HTTPClient, interceptors)
+	return &intercepted
+}
+
+func (c *WorkloadIdentityCredential) GetToken(ctx context.Context) (*AccessToken, error) {
+	if c.TokenFile == "" {
+		return nil, fmt.Errorf("a federated token file is required")
+	}
+
+	content, err := os.ReadFile(c.TokenFile)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read federated token: %v", err)
+	}
+
+	data := url.Values{}
+	data.Add("client_id", c.ClientId)
+	data.Add("client_assertion_type", clientAssertionType)
+	data.Add("client_assertion", strings.TrimSpace(string(content)))
+
+	target := tokenTarget{c.AuthorityUrl, c.TenantId, c.TokenEndpointVersion, c.ResourceId, c.Scope}
+	return requestClientCredentialsToken(ctx, c.HTTPClient, c.RetryPolicy, c.Logger, target, data)
+}
@@ -0,0 +1,67 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/models"
+	"azure-adt-example/digitaltwin/models/rec33"
+	"azure-adt-example/digitaltwin/query"
+	"testing"
+)
+
+// aliasBody is a single row containing the two buildings and company.
+const aliasBody = "{ \"value\": [ { \"company\": { \"$dtId\": \"Company1\" }, \"buildingA\": { \"$dtId\": \"BuildingA\" }, \"buildingB\": { \"$dtId\": \"BuildingB\" } } ], \"continuationToken\": null }"
+
+func TestExecuteBuilder_Aliases(t *testing.T) {
+	var queries []string
+	server := newQueryServer(&queries, aliasBody)
+	defer server.Close()
+
+	buildingA := models.WithAlias(rec33.Building{}, "buildingA")
+	buildingB := models.WithAlias(rec33.Building{}, "buildingB")
+
+	builder := query.NewBuilder(rec33.Company{}, false, false)
+	_ = builder.AddJoin(rec33.Company{}, buildingA, "owns", false, false)
+	_ = builder.AddJoin(rec33.Company{}, buildingB, "owns", false, false)
+
+	results, err := ExecuteBuilder3[rec33.Company, rec33.Building, rec33.Building](newTestClient(server), builder)
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if len(results) != 1 || results[0].Twin1.ExternalId != "Company1" || results[0].Twin2.ExternalId != "BuildingA" || results[0].Twin3.ExternalId != "BuildingB" {
+		t.Errorf("Unexpected results %+v", results)
+	}
+
+	expected := "SELECT company, buildingA, buildingB FROM digitaltwins company JOIN buildingA RELATED company.owns JOIN buildingB RELATED company.owns"
+	if len(queries) != 1 || queries[0] != expected {
+		t.Errorf("Expected query %s, but got %v", expected, queries)
+	}
+}
+
+func TestExecuteBuilder_AliasProjectionOrder(t *testing.T) {
+	var queries []string
+	server := newQueryServer(&queries, aliasBody)
+	defer server.Close()
+
+	buildingA := models.WithAlias(rec33.Building{}, "buildingA")
+	buildingB := models.WithAlias(rec33.Building{}, "buildingB")
+
+	builder := query.NewBuilder(rec33.Company{}, false, false)
+	_ = builder.AddJoin(rec33.Company{}, buildingA, "owns", false, false)
+	_ = builder.AddJoin(rec33.Company{}, buildingB, "owns", false, false)
+	_ = builder.AddProjection(buildingB)
+	_ = builder.AddProjection(buildingA)
+
+	results, err := ExecuteBuilder2[rec33.Building, rec33.Building](newTestClient(server), builder)
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if len(results) != 1 || results[0].Twin1.ExternalId != "BuildingB" || results[0].Twin2.ExternalId != "BuildingA" {
+		t.Errorf("Expected the buildings in the order they were projected, but got %+v", results)
+	}
+
+	expected := "SELECT buildingB, buildingA FROM digitaltwins company JOIN buildingA RELATED company.owns JOIN buildingB RELATED company.owns"
+	if len(queries) != 1 || queries[0] != expected {
+		t.Errorf("Expected query %s, but got %v", expected, queries)
+	}
+}
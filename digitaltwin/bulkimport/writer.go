@@ -0,0 +1,209 @@
+package bulkimport
+
+import (
+	"azure-adt-example/digitaltwin/models"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Section defines the sections of an import file. Sections must be written in order, and
+// each section may only be written once.
+type Section int
+
+const (
+	HeaderSection Section = iota + 1
+	ModelsSection
+	TwinsSection
+	RelationshipsSection
+)
+
+func (s Section) String() string {
+	sections := []string{"Header", "Models", "Twins", "Relationships"}
+	if !s.IsValid() {
+		return fmt.Sprintf("Section(%d)", s)
+	}
+	return sections[s-1]
+}
+
+func (s Section) IsValid() bool {
+	switch s {
+	case HeaderSection, ModelsSection, TwinsSection, RelationshipsSection:
+		return true
+	}
+	return false
+}
+
+// FileVersion is the version of the import file format generated by the Writer.
+const FileVersion = "1.0.0"
+
+// Header defines the values written to the header section of the import file.
+type Header struct {
+	FileVersion  string `json:"fileVersion"`
+	Author       string `json:"author,omitempty"`
+	Organization string `json:"organization,omitempty"`
+}
+
+// Writer generates an Azure Digital Twin import file, which is newline delimited JSON (NDJSON)
+// made up of a header followed by the models, twins and relationships to be imported. The
+// output can be written to any io.Writer, such as a file for inspecting offline, before being
+// uploaded to blob storage for an import job.
+type Writer struct {
+	w       io.Writer
+	section Section
+	err     error
+}
+
+// NewWriter creates a Writer and writes the header section. If the header does not have a
+// FileVersion then FileVersion is used.
+func NewWriter(w io.Writer, header Header) (*Writer, error) {
+	if header.FileVersion == "" {
+		header.FileVersion = FileVersion
+	}
+
+	writer := &Writer{w: w}
+	if err := writer.startSection(HeaderSection); err != nil {
+		return nil, err
+	}
+
+	if err := writer.writeValue(header); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// WriteModels writes DTDL model definitions to the models section. Each model is compacted so
+// that it is written on a single line.
+func (w *Writer) WriteModels(dtdl ...json.RawMessage) error {
+	if err := w.startSection(ModelsSection); err != nil {
+		return err
+	}
+
+	for _, model := range dtdl {
+		var line bytes.Buffer
+		if err := json.Compact(&line, model); err != nil {
+			return fmt.Errorf("unable to compact model definition: %v", err)
+		}
+		if err := w.writeLine(line.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteTwin writes a twin to the twins section, with metadata containing only the model of
+// the twin.
+func (w *Writer) WriteTwin(twin models.IModel) error {
+	document, err := models.NewTwinDocument(twin)
+	if err != nil {
+		return err
+	}
+
+	if id, ok := document["$dtId"]; !ok || string(id) == `""` {
+		return fmt.Errorf("twin of type %T does not have an id", twin)
+	}
+
+	if err = w.startSection(TwinsSection); err != nil {
+		return err
+	}
+
+	return w.writeValue(document)
+}
+
+// WriteTwins writes each of the twins to the twins section, for example a slice of rec33.Level.
+func WriteTwins[T models.IModel](w *Writer, twins []T) error {
+	for _, twin := range twins {
+		if err := w.WriteTwin(twin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteRelationship writes a relationship to the relationships section. The relationship is
+// either models.GenericRelationship, or a type which embeds it and adds the relationship
+// properties. The import file identifies the source twin using "$dtId".
+func (w *Writer) WriteRelationship(relationship any) error {
+	content, err := json.Marshal(relationship)
+	if err != nil {
+		return fmt.Errorf("unable to serialise %T: %v", relationship, err)
+	}
+
+	var identity models.GenericRelationship
+	document := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(content, &document); err == nil {
+		err = json.Unmarshal(content, &identity)
+	}
+	if err != nil {
+		return fmt.Errorf("%T does not serialise to a relationship: %v", relationship, err)
+	}
+
+	if identity.RelationshipId == "" || identity.SourceId == "" || identity.TargetId == "" || identity.Name == "" {
+		return fmt.Errorf("relationship %s must have a source id, target id and relationship name", identity.RelationshipId)
+	}
+
+	document["$dtId"] = document["$sourceId"]
+	delete(document, "$sourceId")
+	delete(document, "$etag")
+
+	if err = w.startSection(RelationshipsSection); err != nil {
+		return err
+	}
+
+	return w.writeValue(document)
+}
+
+// WriteRelationships writes each of the relationships to the relationships section.
+func WriteRelationships[R any](w *Writer, relationships []R) error {
+	for _, relationship := range relationships {
+		if err := w.WriteRelationship(relationship); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// startSection writes the marker for the section if it has not already been started. An
+// error is returned if a later section has already been written.
+func (w *Writer) startSection(section Section) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if section == w.section {
+		return nil
+	}
+
+	if section < w.section {
+		return fmt.Errorf("unable to write to the %s section after the %s section", section, w.section)
+	}
+
+	w.section = section
+	return w.writeValue(map[string]string{"Section": section.String()})
+}
+
+// writeValue writes the value as a single line of JSON.
+func (w *Writer) writeValue(value any) error {
+	line, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("unable to serialise %T: %v", value, err)
+	}
+	return w.writeLine(line)
+}
+
+func (w *Writer) writeLine(line []byte) error {
+	if _, err := w.w.Write(append(line, '\n')); err != nil {
+		return w.fail(fmt.Errorf("unable to write import file: %w", err))
+	}
+	return nil
+}
+
+// fail records a write error so that no further lines are written after a partial write, which
+// would otherwise leave the file in an inconsistent state.
+func (w *Writer) fail(err error) error {
+	w.err = err
+	return err
+}
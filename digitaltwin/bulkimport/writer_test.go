@@ -0,0 +1,109 @@
+package bulkimport
+
+import (
+	"azure-adt-example/digitaltwin/models"
+	"azure-adt-example/digitaltwin/models/rec33"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testIsPartOf struct {
+	models.GenericRelationship
+	Weight int `json:"weight"`
+}
+
+func TestWriter(t *testing.T) {
+	var output bytes.Buffer
+
+	writer, err := NewWriter(&output, Header{Author: "tester"})
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if err = writer.WriteModels(json.RawMessage("{\n  \"@id\": \"dtmi:example:Thing;1\"\n}")); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	buildings := []rec33.Building{{GenericModel: models.GenericModel{ExternalId: "building01", ETag: "W/\"1\""}, Name: "HQ"}}
+	levels := []rec33.Level{{GenericModel: models.GenericModel{ExternalId: "level01"}, Name: "Ground", Number: 0}}
+
+	if err = WriteTwins(writer, buildings); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	if err = WriteTwins(writer, levels); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	relationships := []testIsPartOf{{GenericRelationship: models.GenericRelationship{RelationshipId: "rel01", SourceId: "level01", TargetId: "building01", Name: "isPartOf"}, Weight: 2}}
+	if err = WriteRelationships(writer, relationships); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	expected := []string{
+		`{"Section":"Header"}`,
+		`{"fileVersion":"1.0.0","author":"tester"}`,
+		`{"Section":"Models"}`,
+		`{"@id":"dtmi:example:Thing;1"}`,
+		`{"Section":"Twins"}`,
+		`{"$dtId":"building01","$metadata":{"$model":"dtmi:digitaltwins:rec_3_3:core:Building;1"},"name":"HQ"}`,
+		`{"$dtId":"level01","$metadata":{"$model":"dtmi:digitaltwins:rec_3_3:core:Level;1"},"levelNumber":0,"name":"Ground","personCapacity":0,"personOccupancy":0}`,
+		`{"Section":"Relationships"}`,
+		`{"$dtId":"level01","$relationshipId":"rel01","$relationshipName":"isPartOf","$targetId":"building01","weight":2}`,
+	}
+
+	lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("Expected %d lines, but got %d\n%s", len(expected), len(lines), output.String())
+	}
+
+	for i, line := range lines {
+		if line != expected[i] {
+			t.Errorf("Line %d: expected '%s', but got '%s'", i, expected[i], line)
+		}
+	}
+}
+
+func TestWriter_SectionOrder(t *testing.T) {
+	var output bytes.Buffer
+	writer, _ := NewWriter(&output, Header{})
+
+	_ = writer.WriteTwin(rec33.Building{GenericModel: models.GenericModel{ExternalId: "building01"}})
+
+	err := writer.WriteModels(json.RawMessage(`{}`))
+	if err == nil || err.Error() != "unable to write to the Models section after the Twins section" {
+		t.Errorf("Expected section order error, but got %v", err)
+	}
+}
+
+func TestWriter_InvalidValues(t *testing.T) {
+	var output bytes.Buffer
+	writer, _ := NewWriter(&output, Header{})
+
+	if err := writer.WriteTwin(rec33.Building{Name: "No id"}); err == nil || !strings.Contains(err.Error(), "does not have an id") {
+		t.Errorf("Expected missing id error, but got %v", err)
+	}
+
+	if err := writer.WriteRelationship(models.GenericRelationship{RelationshipId: "rel01"}); err == nil || !strings.Contains(err.Error(), "rel01 must have a source id") {
+		t.Errorf("Expected incomplete relationship error, but got %v", err)
+	}
+
+	if strings.Count(output.String(), "\n") != 2 {
+		t.Errorf("Expected only the header to be written, but got\n%s", output.String())
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWriter_WriteFailure(t *testing.T) {
+	_, err := NewWriter(failingWriter{}, Header{})
+	if err == nil || !strings.Contains(err.Error(), "disk full") {
+		t.Errorf("Expected write error, but got %v", err)
+	}
+}
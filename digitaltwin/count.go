@@ -0,0 +1,63 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/query"
+	"azure-adt-example/tracing"
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// countColumn is the name of the column containing the result of a COUNT() query.
+const countColumn = "COUNT"
+
+// Count queries the Azure Digital Twin for the number of results the query created from the
+// Builder instance would return, without retrieving the twins themselves. The projections and
+// any TOP limit of the Builder are ignored.
+func Count(client *Client, builder *query.Builder) (int, error) {
+	return CountCtx(context.Background(), client, builder)
+}
+
+// CountCtx is the same as Count, but returns the context error if the context is cancelled or
+// its deadline is exceeded.
+func CountCtx(ctx context.Context, client *Client, builder *query.Builder) (count int, err error) {
+	countQuery, err := builder.CreateCountQuery()
+	if err != nil {
+		return 0, fmt.Errorf("unable to generate digital twin query: %s", err)
+	}
+
+	ctx, span := client.tracer().Start(ctx, tracing.SpanCount, tracing.Attr(tracing.AttributeQuery, *countQuery))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	data, err := client.queryTwin(ctx, *countQuery, nil, client.MaxItemsPerPage)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(data.Results) == 0 {
+		return 0, fmt.Errorf("count query returned no results")
+	}
+
+	var row map[string]json.RawMessage
+	if err := json.Unmarshal(data.Results[0], &row); err != nil {
+		return 0, fmt.Errorf("unable to parse %s into a count: %v", data.Results[0], err)
+	}
+
+	content, ok := row[countColumn]
+	if !ok {
+		return 0, fmt.Errorf("count query result does not contain a %s value", countColumn)
+	}
+
+	if err := json.Unmarshal(content, &count); err != nil {
+		return 0, fmt.Errorf("unable to parse %s into a count: %v", content, err)
+	}
+
+	return count, nil
+}
@@ -0,0 +1,92 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/models/rec33"
+	"azure-adt-example/digitaltwin/query"
+	"strings"
+	"testing"
+)
+
+func TestCount(t *testing.T) {
+	var queries []string
+	server := newQueryServer(&queries, "{ \"value\": [ { \"COUNT\": 42 } ], \"continuationToken\": null }")
+	defer server.Close()
+
+	builder := query.NewBuilder(rec33.Building{}, false, false)
+	_ = builder.AddJoin(rec33.Building{}, rec33.Level{}, "isPartOf", false, false)
+	_ = builder.WhereId(rec33.Building{}, "Building1")
+	_ = builder.AddProjection(rec33.Level{})
+
+	count, err := Count(newTestClient(server), builder)
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if count != 42 {
+		t.Errorf("Expected a count of 42, but got %d", count)
+	}
+
+	expected := "SELECT COUNT() FROM digitaltwins building JOIN level RELATED building.isPartOf WHERE building.$dtId = 'Building1'"
+	if len(queries) != 1 || queries[0] != expected {
+		t.Errorf("Expected query %s, but got %v", expected, queries)
+	}
+}
+
+func TestCount_InvalidResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{"NoResults", "{ \"value\": [], \"continuationToken\": null }", "count query returned no results"},
+		{"NoCount", "{ \"value\": [ { \"building\": {} } ], \"continuationToken\": null }", "does not contain a COUNT value"},
+		{"NotNumber", "{ \"value\": [ { \"COUNT\": \"many\" } ], \"continuationToken\": null }", "unable to parse \"many\" into a count"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var queries []string
+			server := newQueryServer(&queries, test.body)
+			defer server.Close()
+
+			_, err := Count(newTestClient(server), query.NewBuilder(rec33.Building{}, false, false))
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("Expected an error containing '%s', but got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestExecute_CountQuery(t *testing.T) {
+	var queries []string
+	server := newQueryServer(&queries, "{ \"value\": [ { \"COUNT\": 42 } ], \"continuationToken\": null }")
+	defer server.Close()
+
+	client := newTestClient(server)
+	builder := query.NewBuilder(rec33.Building{}, false, false)
+	builder.SetCount(true)
+
+	if _, err := ExecuteBuilder[rec33.Building](client, builder); err == nil || !strings.Contains(err.Error(), "use Count") {
+		t.Errorf("Expected ExecuteBuilder to reject the count query, but got %v", err)
+	}
+
+	if _, err := ExecuteProjection[map[string]any](client, builder); err == nil || !strings.Contains(err.Error(), "use Count") {
+		t.Errorf("Expected ExecuteProjection to reject the count query, but got %v", err)
+	}
+
+	if _, err := ExecuteBuilder2[rec33.Building, rec33.Level](client, builder); err == nil || !strings.Contains(err.Error(), "use Count") {
+		t.Errorf("Expected ExecuteBuilder2 to reject the count query, but got %v", err)
+	}
+
+	if len(builder.Projections()) != 0 {
+		t.Errorf("Expected the rejected calls not to add projections, but got %v", builder.Projections())
+	}
+
+	if count, err := Count(client, builder); err != nil || count != 42 {
+		t.Errorf("Expected a count of 42, but got %d, %v", count, err)
+	}
+
+	if len(queries) != 1 {
+		t.Errorf("Expected only the Count query to be sent, but got %v", queries)
+	}
+}
@@ -4,289 +4,501 @@ import (
 	"azure-adt-example/azuread"
 	"azure-adt-example/digitaltwin/models"
 	"azure-adt-example/digitaltwin/query"
+	"azure-adt-example/logging"
+	"azure-adt-example/pipeline"
+	"azure-adt-example/retry"
+	"azure-adt-example/tracing"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"log"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 const apiVersion = "2020-10-31"
 
+// queryChargeHeader is the response header containing the query units charged for a page.
+const queryChargeHeader = "query-charge"
+
+// maxDrainBytes limits how much of an unread response body is discarded to allow the
+// connection to be reused. Larger bodies are closed, which closes the connection.
+const maxDrainBytes = 4096
+
 // Client defines the values required for accessing an Azure Digital Twin instance.
 type Client struct {
 	configuration   *azuread.TwinConfiguration
+	credential      azuread.TokenCredential
 	accessToken     *azuread.AccessToken
 	MaxItemsPerPage uint
+
+	// TokenCache holds the access tokens acquired by the Client. If nil then the tokens are held
+	// in azuread.DefaultTokenCache, which is shared with other clients.
+	TokenCache *azuread.TokenCache
+
+	// Logger receives the log entries of the requests made by the Client. If nil then the logger
+	// of the configuration is used, which logs nothing unless one has been set.
+	Logger logging.Logger
+
+	// HTTPClient sends the requests to the Azure Digital Twin instance. If nil then the HTTP
+	// client of the configuration is used.
+	HTTPClient *http.Client
+
+	// Tracer starts the spans of each query, page and token request, and Meter records the number,
+	// errors and latency of the requests. If nil then nothing is recorded.
+	Tracer tracing.Tracer
+	Meter  tracing.Meter
+
+	// Interceptors see each request to the Azure Digital Twin instance, in order, after it has
+	// been authorised and before it is sent. They run inside the retries, so see every attempt.
+	// Token requests also pass through them, both those made using the client secret in the
+	// configuration and those of a credential given to NewClient which is azuread.Interceptable.
+	Interceptors []pipeline.Interceptor
+
+	// buildOnce creates the chain and the credential from the fields above on the first request,
+	// so the fields must not be changed after then.
+	buildOnce   sync.Once
+	chain       pipeline.Handler
+	tokenSource azuread.TokenCredential
 }
 
-// NewClient creates an instance of the Client type.
-func NewClient(configuration *azuread.TwinConfiguration, accessToken *azuread.AccessToken) *Client {
-	client := Client{configuration: configuration, accessToken: accessToken, MaxItemsPerPage: 1000}
+// NewClient creates an instance of the Client type which acquires access tokens using the
+// credential, such as an azuread.ManagedIdentityCredential. If the credential is nil then tokens
+// are acquired using the client secret in the configuration. An *azuread.AccessToken is used
+// until it expires, after which the client secret is used.
+func NewClient(configuration *azuread.TwinConfiguration, credential azuread.TokenCredential) *Client {
+	client := Client{configuration: configuration, MaxItemsPerPage: 1000}
+	if accessToken, ok := credential.(*azuread.AccessToken); ok {
+		client.accessToken = accessToken
+	} else {
+		client.credential = credential
+	}
 	return &client
 }
 
-// getQueryEndpoint generates the full URL required for querying the Azure Digital Twin
-// instance defined in the Client configuration.
-func (c *Client) getQueryEndpoint() string {
+// logger returns the logger of the Client, or of its configuration if it does not have one.
+func (c *Client) logger() logging.Logger {
+	if c.Logger != nil {
+		return c.Logger
+	}
+	return c.configuration.GetLogger()
+}
+
+// Use adds interceptors to the end of the chain which each request passes through. It must be
+// called before the first request is made.
+func (c *Client) Use(interceptors ...pipeline.Interceptor) {
+	c.Interceptors = append(c.Interceptors, interceptors...)
+}
+
+// tracer returns the tracer of the Client, or tracing.NoopTracer if it does not have one.
+func (c *Client) tracer() tracing.Tracer {
+	return tracing.TracerOrNoop(c.Tracer)
+}
+
+// httpClient returns the HTTP client of the Client, or of its configuration if it does not
+// have one.
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return c.configuration.GetHTTPClient()
+}
+
+// getEndpoint generates the full URL for the path made up of the given segments on the Azure
+// Digital Twin instance defined in the Client configuration. Each segment is escaped so that
+// twin and relationship ids can be used directly.
+func (c *Client) getEndpoint(params url.Values, segments ...string) string {
 	endpoint := c.configuration.URL
-	endpoint.Path = "/query"
 
-	params := url.Values{}
-	params.Add("api-version", apiVersion)
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = url.PathEscape(segment)
+	}
+	endpoint.Path = "/" + strings.Join(segments, "/")
+	endpoint.RawPath = "/" + strings.Join(escaped, "/")
+
+	if params == nil {
+		params = url.Values{}
+	}
+	if params.Get("api-version") == "" {
+		params.Set("api-version", apiVersion)
+	}
 
 	endpoint.RawQuery = params.Encode()
 
 	return endpoint.String()
 }
 
-func (c *Client) getBuilderResults(builder *query.Builder, err error) (digitalTwinResults, error) {
-	queryResults := make(digitalTwinResults, 0)
-	var continuationToken *string
-	generatedQuery, err := builder.CreateQuery()
-	if err != nil {
-		return nil, fmt.Errorf("unable to generate digital twin query: %s", err)
+// getQueryEndpoint generates the full URL required for querying the Azure Digital Twin
+// instance defined in the Client configuration.
+func (c *Client) getQueryEndpoint() string {
+	return c.getEndpoint(nil, "query")
+}
+
+// authenticate returns an access token which has not expired. The access token given to
+// NewClient is used until it expires, after which tokens are acquired through the token
+// cache, so that concurrent requests share a single token request.
+func (c *Client) authenticate(ctx context.Context) (*azuread.AccessToken, error) {
+	if c.accessToken != nil && c.accessToken.ExpiresOn > time.Now().Unix() {
+		return c.accessToken, nil
 	}
 
-	for {
-		queryData, err := c.queryTwin(*generatedQuery, continuationToken)
-		if err != nil {
-			return nil, err
-		}
+	c.buildOnce.Do(c.build)
+	key := azuread.CacheKey(c.configuration, c.tokenSource)
 
-		var data QueryResultGeneric
-		err = json.Unmarshal(*queryData, &data)
-		if err != nil {
-			return nil, fmt.Errorf("unable to extract digital twin results: %v", err)
-		}
+	cache := c.TokenCache
+	if cache == nil {
+		cache = azuread.DefaultTokenCache
+	}
 
-		queryResults = append(queryResults, data.Results...)
+	return cache.GetToken(ctx, key, &tracedCredential{credential: c.tokenSource, tracer: c.tracer()})
+}
 
-		if !data.HasContinuationToken() {
-			break
-		}
+// tracedCredential starts a span for each token the credential acquires.
+type tracedCredential struct {
+	credential azuread.TokenCredential
+	tracer     tracing.Tracer
+}
+
+func (t *tracedCredential) GetToken(ctx context.Context) (*azuread.AccessToken, error) {
+	ctx, span := t.tracer.Start(ctx, tracing.SpanAcquireToken, tracing.Attr(tracing.AttributeCredential, fmt.Sprintf("%T", t.credential)))
+	defer span.End()
 
-		continuationToken = &data.ContinuationToken
+	token, err := t.credential.GetToken(ctx)
+	span.RecordError(err)
+	return token, err
+}
+
+// bearerToken returns the access token used to authorise requests.
+func (c *Client) bearerToken(ctx context.Context) (string, error) {
+	accessToken, err := c.authenticate(ctx)
+	if err != nil {
+		return "", err
 	}
-	log.Printf("Total number of records: %d", len(queryResults))
-	return queryResults, nil
+	return accessToken.AccessToken, nil
 }
 
-// queryTwin contains the logic for querying the Azure Digital Twin instance. It returns a
-// byte array of data retrieved from the API.
-func (c *Client) queryTwin(query string, continuationToken *string) (*[]byte, error) {
-	currentTime := time.Now().Unix()
-	var err error
-	endpoint := c.getQueryEndpoint()
+// handler returns the chain which each request passes through, creating it on the first request.
+func (c *Client) handler() pipeline.Handler {
+	c.buildOnce.Do(c.build)
+	return c.chain
+}
 
-	// If the access token has not been provided, or has expired, then refresh the
-	// access token
-	if c.accessToken == nil || c.accessToken.ExpiresOn <= currentTime {
-		c.accessToken, err = azuread.GetBearerToken(c.configuration)
-		if err != nil {
-			return nil, err
-		}
+// build creates the chain which each request passes through, and the credential used to acquire
+// tokens. The built in interceptors log each request, authorise it, retry it according to the
+// configured retry policy and trace each attempt, before the interceptors of the Client are run.
+// Authorisation is outside the retries, as the token requests are retried by the credential.
+// Responses are decompressed before the interceptors of the Client see them. A credential which
+// is azuread.Interceptable is replaced by a copy whose token requests pass through them too.
+func (c *Client) build() {
+	c.tokenSource = c.credential
+	if c.tokenSource == nil {
+		secretCredential := azuread.NewClientSecretCredential(c.configuration)
+		secretCredential.HTTPClient = c.httpClient()
+		c.tokenSource = secretCredential
+	}
+	if credential, ok := c.tokenSource.(azuread.Interceptable); ok && len(c.Interceptors) > 0 {
+		c.tokenSource = credential.WithInterceptors(c.Interceptors...)
 	}
 
-	var requestBody string
-	if continuationToken == nil {
-		requestBody = fmt.Sprintf(`{ "query": "%s" }`, query)
-	} else {
-		continuationData, _ := json.Marshal(*continuationToken)
-		requestBody = fmt.Sprintf(`{ "query": "%s", "continuationToken": %s }`, query, string(continuationData))
+	interceptors := []pipeline.Interceptor{
+		pipeline.Logging(c.logger()),
+		pipeline.BearerToken(c.bearerToken),
+		pipeline.Retry(c.configuration.GetRetryPolicy()),
+		pipeline.Tracing(c.Tracer, c.Meter),
 	}
+	interceptors = append(interceptors, c.Interceptors...)
+	interceptors = append(interceptors, pipeline.Decompress())
 
-	jsonData := []byte(requestBody)
-	maxItemsPerPage := c.MaxItemsPerPage
-	if maxItemsPerPage == 0 {
-		maxItemsPerPage = 1
+	c.chain = pipeline.Chain(c.httpClient().Do, interceptors...)
+}
+
+// sendRequest sends an authenticated request to the Azure Digital Twin instance through the
+// chain of interceptors. The caller is responsible for closing the body of the returned
+// response.
+func (c *Client) sendRequest(ctx context.Context, method string, endpoint string, body []byte, header http.Header) (*http.Response, error) {
+	var bodyReader io.Reader
+	if body != nil {
+		bodyReader = bytes.NewBuffer(body)
 	}
 
-	req, err := http.NewRequest("POST", endpoint, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bodyReader)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.accessToken.AccessToken))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("max-items-per-page", fmt.Sprint(maxItemsPerPage))
 
-	log.Printf("Querying endpoint %s with query:\n%s", endpoint, query)
-
-	httpClient := &http.Client{}
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return nil, err
-	} else if resp.StatusCode != 200 {
-		respContent, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			return nil, fmt.Errorf("non-success status code returned: %d", resp.StatusCode)
-		} else {
-			var respError QueryError
-			_ = json.Unmarshal(respContent, &respError)
-			return nil, fmt.Errorf("non-success status code returned: %d\nQuery: %s\n%s", resp.StatusCode, query, respError.ErrorDetail.Message)
+	for key, values := range header {
+		for _, value := range values {
+			req.Header.Add(key, value)
 		}
 	}
-	defer func(Body io.ReadCloser) {
-		err := Body.Close()
+	if body != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return c.handler()(req)
+}
+
+// readErrorDetail reads the Azure Digital Twin error from the body of a non-success response.
+// If the body does not contain an error then the returned ErrorDetail is empty.
+func readErrorDetail(resp *http.Response) ErrorDetail {
+	var respError QueryError
+	_ = json.NewDecoder(resp.Body).Decode(&respError)
+
+	return respError.ErrorDetail
+}
+
+// readResponse parses the body of a successful response into the type T, decoding it as it is
+// read rather than buffering the whole body first.
+func readResponse[T any](resp *http.Response) (*T, error) {
+	result := new(T)
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return nil, fmt.Errorf("unable to parse response into %T: %v", *result, err)
+	}
+
+	return result, nil
+}
+
+// listAll retrieves every page of a list API, starting at the endpoint and following the
+// next link of each page until there are no more, or the context is cancelled. The onError
+// function creates the error returned for a non-success response.
+func listAll[T any](ctx context.Context, c *Client, endpoint string, onError func(*http.Response) error) ([]T, error) {
+	results := make([]T, 0)
+
+	for endpoint != "" {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		page, err := getListPage[T](ctx, c, endpoint, onError)
 		if err != nil {
-			log.Printf("Unable to close body: %v", err)
+			return nil, err
 		}
-	}(resp.Body)
 
-	log.Printf("Response code: %d", resp.StatusCode)
+		results = append(results, page.Value...)
 
-	respContent, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+		if endpoint, err = c.resolveNextLink(page.NextLink); err != nil {
+			return nil, err
+		}
 	}
 
-	return &respContent, nil
+	return results, nil
 }
 
-// ExecuteBuilder queries the Azure Digital Twin using the query creating from the Builder instance. It
-// returns an array of models.IModel types.
-func ExecuteBuilder[T1 models.IModel](client *Client, builder *query.Builder) ([]T1, error) {
-	type1 := *new(T1)
+// resolveNextLink resolves the next link of a page against the instance URL. As the request is
+// sent with the access token, links to any other scheme or host are rejected.
+func (c *Client) resolveNextLink(nextLink string) (string, error) {
+	if nextLink == "" {
+		return "", nil
+	}
+
+	link, err := url.Parse(nextLink)
+	if err != nil {
+		return "", fmt.Errorf("invalid next link %s: %v", nextLink, err)
+	}
+
+	link = c.configuration.URL.ResolveReference(link)
+	if link.Scheme != c.configuration.URL.Scheme || link.Host != c.configuration.URL.Host {
+		return "", fmt.Errorf("next link %s is not on the instance host %s", nextLink, c.configuration.URL.Host)
+	}
 
-	var err error
+	return link.String(), nil
+}
 
-	if err = builder.AddProjection(type1); err != nil {
+// getListPage retrieves a single page of a list API.
+func getListPage[T any](ctx context.Context, c *Client, endpoint string, onError func(*http.Response) error) (*listResult[T], error) {
+	resp, err := c.sendRequest(ctx, "GET", endpoint, nil, nil)
+	if err != nil {
 		return nil, err
 	}
+	defer c.closeBody(resp.Body)
 
-	queryResults, err := client.getBuilderResults(builder, err)
+	if resp.StatusCode != http.StatusOK {
+		return nil, onError(resp)
+	}
+
+	return readResponse[listResult[T]](resp)
+}
+
+// closeBody closes a response body, logging any failure. Any of the body which has not been
+// read, such as the whitespace after a JSON document, is drained first so that the connection
+// can be reused.
+func (c *Client) closeBody(body io.ReadCloser) {
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, maxDrainBytes))
+	err := body.Close()
 	if err != nil {
-		return nil, err
+		c.logger().Log(logging.LevelWarn, "unable to close body", logging.F(logging.FieldError, err))
 	}
+}
 
-	results := make([]T1, len(queryResults))
+// queryTwin contains the logic for querying the Azure Digital Twin instance. It returns the
+// page of results retrieved from the API, decoded as the response is read. The request is
+// aborted if the context is cancelled.
+func (c *Client) queryTwin(ctx context.Context, query string, continuationToken *string, maxItemsPerPage uint) (*QueryResultGeneric, error) {
+	endpoint := c.getQueryEndpoint()
 
-	for i, v := range queryResults {
-		t1 := new(T1)
+	var requestBody string
+	if continuationToken == nil {
+		requestBody = fmt.Sprintf(`{ "query": "%s" }`, query)
+	} else {
+		continuationData, _ := json.Marshal(*continuationToken)
+		requestBody = fmt.Sprintf(`{ "query": "%s", "continuationToken": %s }`, query, string(continuationData))
+	}
 
-		content, ok := v[type1.Alias()]
-		if ok {
-			err = json.Unmarshal(content, t1)
-			if err != nil {
-				return nil, fmt.Errorf("unable to parse %v into %T", content, *t1)
-			}
-			results[i] = *t1
-		}
+	jsonData := []byte(requestBody)
+	if maxItemsPerPage == 0 {
+		maxItemsPerPage = 1
 	}
 
-	return results, nil
-}
+	header := http.Header{}
+	header.Set("max-items-per-page", fmt.Sprint(maxItemsPerPage))
 
-// ExecuteBuilder2 queries the Azure Digital Twin using the query creating from the Builder instance. It
-// returns an array of TwinResult2 objects which are typed to models.IModel types.
-func ExecuteBuilder2[T1, T2 models.IModel](client *Client, builder *query.Builder) ([]TwinResult2[T1, T2], error) {
-	type1 := *new(T1)
-	type2 := *new(T2)
+	c.logger().Log(logging.LevelDebug, "querying digital twins", logging.F(logging.FieldQuery, query))
 
-	err := builder.AddProjection(type1)
+	// Each page is retried independently so that a transient failure part way through a
+	// paged query resumes from the current continuation token
+	// Queries only read, so are safe to retry after a transport error
+	resp, err := c.sendRequest(retry.WithIdempotent(ctx), "POST", endpoint, jsonData, header)
 	if err != nil {
 		return nil, err
 	}
+	defer c.closeBody(resp.Body)
 
-	err = builder.AddProjection(type2)
-	if err != nil {
-		return nil, err
+	span := tracing.SpanFromContext(ctx)
+	span.SetAttributes(tracing.Attr(tracing.AttributeStatus, resp.StatusCode))
+	if charge, err := strconv.ParseFloat(resp.Header.Get(queryChargeHeader), 64); err == nil {
+		span.SetAttributes(tracing.Attr(tracing.AttributeQueryCharge, charge))
+		tracing.MeterOrNoop(c.Meter).Histogram(tracing.MetricQueryCharge).Record(ctx, charge)
+	}
+
+	if resp.StatusCode != 200 {
+		responseError := newResponseError(resp, "", "")
+		responseError.Query = query
+		return nil, responseError
 	}
 
-	queryResults, err := client.getBuilderResults(builder, err)
+	page, err := decodeQueryPage(resp.Body)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("unable to extract digital twin results: %v", err)
 	}
 
-	results := make([]TwinResult2[T1, T2], len(queryResults))
+	span.SetAttributes(tracing.Attr(tracing.AttributeResultCount, len(page.Results)))
+	return page, nil
+}
 
-	for i, v := range queryResults {
-		t1 := new(T1)
-		t2 := new(T2)
+// decodeQueryPage decodes a page of query results as it is read, one row at a time, so that
+// the whole response body is never held in memory alongside the decoded rows. Each row is kept
+// as raw JSON so that it is only decoded when the row is used, and then straight into its type
+// without an intermediate map of its columns.
+func decodeQueryPage(reader io.Reader) (*QueryResultGeneric, error) {
+	decoder := json.NewDecoder(reader)
+	page := &QueryResultGeneric{}
 
-		content, ok := v[type1.Alias()]
-		if ok {
-			err = json.Unmarshal(content, t1)
-			if err != nil {
-				return nil, fmt.Errorf("unable to parse %v into %T", content, *t1)
-			}
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
 		}
 
-		if content, ok := v[type2.Alias()]; ok {
-			err = json.Unmarshal(content, t2)
-			if err != nil {
-				return nil, fmt.Errorf("unable to parse %v into %T", content, *t2)
+		switch token {
+		case "value":
+			if err = expectDelim(decoder, '['); err != nil {
+				return nil, err
 			}
+			for decoder.More() {
+				page.Results = append(page.Results, nil)
+				if err = decoder.Decode(&page.Results[len(page.Results)-1]); err != nil {
+					return nil, err
+				}
+			}
+			err = expectDelim(decoder, ']')
+		case "continuationToken":
+			err = decoder.Decode(&page.ContinuationToken)
+		default:
+			var ignored json.RawMessage
+			err = decoder.Decode(&ignored)
+		}
+
+		if err != nil {
+			return nil, err
 		}
+	}
 
-		results[i] = NewTwinResult2(t1, t2)
+	if err := expectDelim(decoder, '}'); err != nil {
+		return nil, err
 	}
 
-	return results, nil
+	return page, nil
 }
 
-// ExecuteBuilder3 queries the Azure Digital Twin using the query creating from the Builder instance. It
-// returns an array of TwinResult3 objects which are typed to models.IModel types.
-func ExecuteBuilder3[T1, T2, T3 models.IModel](client *Client, builder *query.Builder) ([]TwinResult3[T1, T2, T3], error) {
-	type1 := *new(T1)
-	type2 := *new(T2)
-	type3 := *new(T3)
-
-	err := builder.AddProjection(type1)
+// expectDelim reads the next token, returning an error if it is not the delimiter.
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	err = builder.AddProjection(type2)
-	if err != nil {
-		return nil, err
+	if token != delim {
+		return fmt.Errorf("expected '%s' but found '%v'", delim, token)
 	}
+	return nil
+}
 
-	err = builder.AddProjection(type3)
-	if err != nil {
-		return nil, err
-	}
+// ExecuteBuilder queries the Azure Digital Twin using the query creating from the Builder instance. It
+// returns an array of models.IModel types.
+func ExecuteBuilder[T1 models.IModel](client *Client, builder *query.Builder) ([]T1, error) {
+	return ExecuteBuilderCtx[T1](context.Background(), client, builder)
+}
 
-	queryResults, err := client.getBuilderResults(builder, err)
+// ExecuteBuilderCtx is the same as ExecuteBuilder, but stops retrieving results and returns the
+// context error if the context is cancelled or its deadline is exceeded.
+func ExecuteBuilderCtx[T1 models.IModel](ctx context.Context, client *Client, builder *query.Builder) ([]T1, error) {
+	pager, err := NewPager[T1](client, builder)
 	if err != nil {
 		return nil, err
 	}
 
-	results := make([]TwinResult3[T1, T2, T3], len(queryResults))
+	return collect(ctx, pager)
+}
 
-	for i, v := range queryResults {
-		t1 := new(T1)
-		t2 := new(T2)
-		t3 := new(T3)
+// ExecuteBuilder2 queries the Azure Digital Twin using the query creating from the Builder instance. It
+// returns an array of TwinResult2 objects which are typed to models.IModel types.
+func ExecuteBuilder2[T1, T2 models.IModel](client *Client, builder *query.Builder) ([]TwinResult2[T1, T2], error) {
+	return ExecuteBuilder2Ctx[T1, T2](context.Background(), client, builder)
+}
 
-		content, ok := v[type1.Alias()]
-		if ok {
-			err = json.Unmarshal(content, t1)
-			if err != nil {
-				return nil, fmt.Errorf("unable to parse %v into %T", content, *t1)
-			}
-		}
+// ExecuteBuilder2Ctx is the same as ExecuteBuilder2, but stops retrieving results and returns the
+// context error if the context is cancelled or its deadline is exceeded.
+func ExecuteBuilder2Ctx[T1, T2 models.IModel](ctx context.Context, client *Client, builder *query.Builder) ([]TwinResult2[T1, T2], error) {
+	pager, err := NewPager2[T1, T2](client, builder)
+	if err != nil {
+		return nil, err
+	}
 
-		if content, ok := v[type2.Alias()]; ok {
-			err = json.Unmarshal(content, t2)
-			if err != nil {
-				return nil, fmt.Errorf("unable to parse %v into %T", content, *t2)
-			}
-		}
+	return collect(ctx, pager)
+}
 
-		if content, ok := v[type3.Alias()]; ok {
-			err = json.Unmarshal(content, t3)
-			if err != nil {
-				return nil, fmt.Errorf("unable to parse %v into %T", content, *t3)
-			}
-		}
+// ExecuteBuilder3 queries the Azure Digital Twin using the query creating from the Builder instance. It
+// returns an array of TwinResult3 objects which are typed to models.IModel types.
+func ExecuteBuilder3[T1, T2, T3 models.IModel](client *Client, builder *query.Builder) ([]TwinResult3[T1, T2, T3], error) {
+	return ExecuteBuilder3Ctx[T1, T2, T3](context.Background(), client, builder)
+}
 
-		results[i] = NewTwinResult3(t1, t2, t3)
+// ExecuteBuilder3Ctx is the same as ExecuteBuilder3, but stops retrieving results and returns the
+// context error if the context is cancelled or its deadline is exceeded.
+func ExecuteBuilder3Ctx[T1, T2, T3 models.IModel](ctx context.Context, client *Client, builder *query.Builder) ([]TwinResult3[T1, T2, T3], error) {
+	pager, err := NewPager3[T1, T2, T3](client, builder)
+	if err != nil {
+		return nil, err
 	}
 
-	return results, nil
+	return collect(ctx, pager)
 }
@@ -5,7 +5,12 @@ import (
 	"azure-adt-example/digitaltwin/models"
 	"azure-adt-example/digitaltwin/models/rec33"
 	"azure-adt-example/digitaltwin/query"
+	"azure-adt-example/pipeline"
+	"azure-adt-example/retry"
+	"azure-adt-example/tracing"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -13,6 +18,8 @@ import (
 	"net/url"
 	"reflect"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -42,7 +49,7 @@ func TestClient_getQueryEndpoint(t *testing.T) {
 }
 
 func TestClient_queryTwin(t *testing.T) {
-	expectedQueryResponse := "Expected response"
+	expectedQueryResponse := "{ \"value\": [ { \"building\": { \"$dtId\": \"building01\" } } ], \"continuationToken\": \"token1\" }"
 	expectedBody := "{ \"query\": \"SELECT * FROM digitaltwins\" }"
 	var queryRequest *http.Request
 	var queryBody string
@@ -74,14 +81,15 @@ func TestClient_queryTwin(t *testing.T) {
 
 	client := NewClient(&conf, &token)
 
-	data, err := client.queryTwin("SELECT * FROM digitaltwins", nil)
+	data, err := client.queryTwin(context.Background(), "SELECT * FROM digitaltwins", nil, client.MaxItemsPerPage)
 	if err != nil {
 		t.Errorf("Expected nil error, but got %v", err)
 		t.FailNow()
 	}
 
-	if !reflect.DeepEqual(*data, []byte(expectedQueryResponse)) {
-		t.Errorf("Expected response '%s', but got '%s'", expectedQueryResponse, string(*data))
+	expectedResults := digitalTwinResults{json.RawMessage("{ \"building\": { \"$dtId\": \"building01\" } }")}
+	if !reflect.DeepEqual(data.Results, expectedResults) || data.ContinuationToken != "token1" {
+		t.Errorf("Expected response '%s', but got '%+v'", expectedQueryResponse, *data)
 		t.FailNow()
 	}
 
@@ -124,7 +132,7 @@ func TestClient_queryTwin_failedAuth(t *testing.T) {
 
 	expectedError := "received error response from authority url: 400"
 
-	_, err := client.queryTwin("SELECT * FROM digitaltwins", nil)
+	_, err := client.queryTwin(context.Background(), "SELECT * FROM digitaltwins", nil, client.MaxItemsPerPage)
 	if err == nil {
 		t.Error("Expected error, but got nil")
 	} else if !strings.Contains(err.Error(), expectedError) {
@@ -139,7 +147,7 @@ func TestClient_queryTwin_ContinuationToken(t *testing.T) {
 			authResponse := getValidAuthenticationResponse()
 			fmt.Fprintf(w, authResponse)
 		} else if strings.HasPrefix(req.RequestURI, "/query?api-version") && req.Method == "POST" {
-			fmt.Fprintf(w, "Expected response")
+			fmt.Fprintf(w, "{ \"value\": [] }")
 			bodyData, _ := ioutil.ReadAll(req.Body)
 			queryBody = string(bodyData)
 		}
@@ -165,7 +173,7 @@ func TestClient_queryTwin_ContinuationToken(t *testing.T) {
 
 	client := NewClient(&conf, &token)
 
-	_, err := client.queryTwin(query, &continuationToken)
+	_, err := client.queryTwin(context.Background(), query, &continuationToken, client.MaxItemsPerPage)
 	if err != nil {
 		t.Errorf("Expected nil error, but got %v", err)
 		t.FailNow()
@@ -206,7 +214,7 @@ func TestClient_queryTwin_FailedRequest(t *testing.T) {
 
 	client := NewClient(&conf, &token)
 
-	_, err := client.queryTwin(query, nil)
+	_, err := client.queryTwin(context.Background(), query, nil, client.MaxItemsPerPage)
 	if err == nil {
 		t.Logf("Expected error but got nil")
 		t.FailNow()
@@ -361,6 +369,144 @@ func TestExecuteBuilder3(t *testing.T) {
 	}
 }
 
+func TestExecuteBuilderCtx_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	pageCount := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if strings.HasPrefix(req.RequestURI, "/query?api-version") && req.Method == "POST" {
+			pageCount++
+			// Every page claims there are more results, cancelling after the first page is served
+			fmt.Fprintf(w, "{ \"value\": [], \"continuationToken\": \"next page\" }")
+			cancel()
+		}
+	}))
+	defer server.Close()
+
+	serverUrl, _ := url.Parse(server.URL)
+
+	conf := azuread.TwinConfiguration{
+		URL:          *serverUrl,
+		ClientId:     "client1",
+		ClientSecret: "secret1",
+		TenantId:     "tenant1",
+		ResourceId:   "resource1",
+		AuthorityUrl: *serverUrl,
+	}
+
+	token := azuread.AccessToken{AccessToken: "abc123", ExpiresOn: time.Now().Add(time.Hour).Unix()}
+	client := NewClient(&conf, &token)
+
+	builder := query.NewBuilder(rec33.Building{}, false, false)
+
+	_, err := ExecuteBuilderCtx[rec33.Building](ctx, client, builder)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled error, but got %v", err)
+	}
+
+	if pageCount != 1 {
+		t.Errorf("Expected a single page to be requested, but got %d", pageCount)
+	}
+}
+
+func TestClient_queryTwin_Deadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.RequestURI == "/tenant1/oauth2/token" && req.Method == "POST" {
+			fmt.Fprint(w, getValidAuthenticationResponse())
+			return
+		}
+
+		_, _ = ioutil.ReadAll(req.Body)
+		select {
+		case <-req.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	serverUrl, _ := url.Parse(server.URL)
+
+	conf := azuread.TwinConfiguration{
+		URL:          *serverUrl,
+		ClientId:     "client1",
+		ClientSecret: "secret1",
+		TenantId:     "tenant1",
+		ResourceId:   "resource1",
+		AuthorityUrl: *serverUrl,
+	}
+
+	client := NewClient(&conf, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := client.queryTwin(ctx, "SELECT * FROM digitaltwins", nil, client.MaxItemsPerPage)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded error, but got %v", err)
+	}
+}
+
+func TestExecuteBuilder_RetriesThrottledPage(t *testing.T) {
+	var tokensSent []string
+	throttled := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.RequestURI == "/tenant1/oauth2/token" && req.Method == "POST" {
+			authResponse := getValidAuthenticationResponse()
+			fmt.Fprintf(w, authResponse)
+		} else if strings.HasPrefix(req.RequestURI, "/query?api-version") && req.Method == "POST" {
+			var body struct {
+				ContinuationToken string `json:"continuationToken"`
+			}
+			_ = json.NewDecoder(req.Body).Decode(&body)
+			tokensSent = append(tokensSent, body.ContinuationToken)
+
+			if body.ContinuationToken == "" {
+				fmt.Fprintf(w, "{ \"value\": [ { \"building\": { \"$dtId\": \"building01\" } } ], \"continuationToken\": \"page2\" }")
+			} else if !throttled {
+				throttled = true
+				w.Header().Set("Retry-After", "0")
+				w.WriteHeader(http.StatusTooManyRequests)
+			} else {
+				fmt.Fprintf(w, "{ \"value\": [ { \"building\": { \"$dtId\": \"building02\" } } ] }")
+			}
+		}
+	}))
+	defer server.Close()
+
+	serverUrl, _ := url.Parse(server.URL)
+
+	conf := azuread.TwinConfiguration{
+		URL:          *serverUrl,
+		ClientId:     "client1",
+		ClientSecret: "secret1",
+		TenantId:     "tenant1",
+		ResourceId:   "resource1",
+		AuthorityUrl: *serverUrl,
+		RetryPolicy:  &retry.Policy{MaxAttempts: 3, StatusCodes: []int{http.StatusTooManyRequests}},
+	}
+
+	token := azuread.AccessToken{AccessToken: "abc123"}
+	client := NewClient(&conf, &token)
+
+	builder := query.NewBuilder(rec33.Building{}, false, false)
+
+	result, err := ExecuteBuilder[rec33.Building](client, builder)
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if len(result) != 2 {
+		t.Errorf("Expected 2 results, but got %d", len(result))
+	}
+
+	expectedTokens := []string{"", "page2", "page2"}
+	if !reflect.DeepEqual(tokensSent, expectedTokens) {
+		t.Errorf("Expected continuation tokens %v, but got %v", expectedTokens, tokensSent)
+	}
+}
+
 func find[T models.IModel](list []T, f func(T) bool) bool {
 	for _, v := range list {
 		if f(v) {
@@ -406,8 +552,8 @@ func getSingleEntityResponseBody() string {
 	building1Body, _ := json.Marshal(building1)
 	building2Body, _ := json.Marshal(building2)
 
-	queryResult := QueryResultGeneric{
-		Results: digitalTwinResults{
+	queryResult := queryResultBody{
+		Results: []map[string]json.RawMessage{
 			{
 				"building": building1Body,
 			},
@@ -474,8 +620,8 @@ func get2EntityResponseBody() string {
 	building1Body, _ := json.Marshal(building1)
 	building2Body, _ := json.Marshal(building2)
 
-	queryResult := QueryResultGeneric{
-		Results: digitalTwinResults{
+	queryResult := queryResultBody{
+		Results: []map[string]json.RawMessage{
 			{
 				"company":  company1Body,
 				"building": building1Body,
@@ -570,8 +716,8 @@ func get3EntityResponseBody() string {
 	level1Body, _ := json.Marshal(level1)
 	level2Body, _ := json.Marshal(level2)
 
-	queryResult := QueryResultGeneric{
-		Results: digitalTwinResults{
+	queryResult := queryResultBody{
+		Results: []map[string]json.RawMessage{
 			{
 				"company":  company1Body,
 				"building": building1Body,
@@ -590,3 +736,251 @@ func get3EntityResponseBody() string {
 
 	return string(resultBody)
 }
+
+type staticCredential struct {
+	calls int
+}
+
+func (s *staticCredential) GetToken(context.Context) (*azuread.AccessToken, error) {
+	s.calls++
+	return &azuread.AccessToken{AccessToken: "credential-token", ExpiresOn: time.Now().Add(time.Hour).Unix()}, nil
+}
+
+func TestNewClient(t *testing.T) {
+	var authorization []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authorization = append(authorization, req.Header.Get("Authorization"))
+		fmt.Fprint(w, "{ \"value\": [], \"continuationToken\": null }")
+	}))
+	defer server.Close()
+
+	serverUrl, _ := url.Parse(server.URL)
+	credential := &staticCredential{}
+	client := NewClient(&azuread.TwinConfiguration{URL: *serverUrl}, credential)
+	client.TokenCache = azuread.NewTokenCache(azuread.DefaultRefreshMargin)
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.queryTwin(context.Background(), "SELECT * FROM digitaltwins", nil, client.MaxItemsPerPage); err != nil {
+			t.Fatalf("Expected nil error, but got %v", err)
+		}
+	}
+
+	if credential.calls != 1 {
+		t.Errorf("Expected the token to be acquired once, but it was acquired %d times", credential.calls)
+	}
+
+	if authorization[1] != "Bearer credential-token" {
+		t.Errorf("Unexpected authorization header '%s'", authorization[1])
+	}
+}
+
+func TestClient_ConcurrentRequestsShareToken(t *testing.T) {
+	var tokenRequests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.RequestURI == "/tenant1/oauth2/token" && req.Method == "POST" {
+			atomic.AddInt32(&tokenRequests, 1)
+			time.Sleep(20 * time.Millisecond)
+			fmt.Fprintf(w, "{ \"token_type\": \"Bearer\", \"expires_in\": 3599, \"access_token\": \"abc123\" }")
+			return
+		}
+		fmt.Fprint(w, "{ \"value\": [], \"continuationToken\": null }")
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	client.TokenCache = azuread.NewTokenCache(azuread.DefaultRefreshMargin)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.queryTwin(context.Background(), "SELECT * FROM digitaltwins", nil, client.MaxItemsPerPage); err != nil {
+				t.Errorf("Expected nil error, but got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if tokenRequests != 1 {
+		t.Errorf("Expected concurrent requests to share 1 token request, but got %d", tokenRequests)
+	}
+}
+
+func TestClient_Interceptors(t *testing.T) {
+	var received []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received = append(received, req)
+		if req.RequestURI == "/tenant1/oauth2/token" && req.Method == "POST" {
+			fmt.Fprintf(w, "{ \"token_type\": \"Bearer\", \"expires_in\": 3599, \"access_token\": \"abc123\" }")
+			return
+		}
+		fmt.Fprint(w, "{ \"value\": [], \"continuationToken\": null }")
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	client.TokenCache = azuread.NewTokenCache(azuread.DefaultRefreshMargin)
+	client.configuration.RetryPolicy = &retry.Policy{MaxAttempts: 2, BaseDelay: time.Millisecond, StatusCodes: []int{http.StatusServiceUnavailable}}
+
+	var audit []string
+	faults := 1
+	client.Use(
+		pipeline.Header("x-ms-client-request-id", "request1"),
+		func(req *http.Request, next pipeline.Handler) (*http.Response, error) {
+			audit = append(audit, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+			return next(req)
+		},
+		func(req *http.Request, next pipeline.Handler) (*http.Response, error) {
+			if req.URL.Path == "/query" && faults > 0 {
+				faults--
+				return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody, Request: req}, nil
+			}
+			return next(req)
+		},
+	)
+
+	if _, err := client.queryTwin(context.Background(), "SELECT * FROM digitaltwins", nil, client.MaxItemsPerPage); err != nil {
+		t.Fatalf("Expected the injected fault to be retried, but got %v", err)
+	}
+
+	expected := []string{"POST /tenant1/oauth2/token", "POST /query", "POST /query"}
+	if !reflect.DeepEqual(audit, expected) {
+		t.Errorf("Expected audited requests %v, but got %v", expected, audit)
+	}
+
+	if len(received) != 2 {
+		t.Fatalf("Expected the token and one query request to reach the server, but got %d", len(received))
+	}
+	for _, req := range received {
+		if req.Header.Get("x-ms-client-request-id") != "request1" {
+			t.Errorf("Expected the custom header on %s", req.URL.Path)
+		}
+	}
+	if received[1].Header.Get("Authorization") != "Bearer abc123" {
+		t.Errorf("Unexpected authorization header '%s'", received[1].Header.Get("Authorization"))
+	}
+}
+
+func TestClient_InterceptorsCredential(t *testing.T) {
+	var queries []string
+	server := newQueryServer(&queries, "{ \"value\": [], \"continuationToken\": null }")
+	defer server.Close()
+
+	configuration := newTestClient(server).configuration
+	credential := azuread.NewChainedCredential(azuread.NewClientSecretCredential(configuration))
+
+	client := NewClient(configuration, credential)
+	client.TokenCache = azuread.NewTokenCache(azuread.DefaultRefreshMargin)
+
+	var audit []string
+	client.Use(func(req *http.Request, next pipeline.Handler) (*http.Response, error) {
+		audit = append(audit, fmt.Sprintf("%s %s", req.Method, req.URL.Path))
+		return next(req)
+	})
+
+	if _, err := client.queryTwin(context.Background(), "SELECT * FROM digitaltwins", nil, client.MaxItemsPerPage); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	expected := []string{"POST /tenant1/oauth2/token", "POST /query"}
+	if !reflect.DeepEqual(audit, expected) {
+		t.Errorf("Expected audited requests %v, but got %v", expected, audit)
+	}
+
+	if len(queries) != 1 {
+		t.Errorf("Expected 1 query, but got %v", queries)
+	}
+}
+
+func TestClient_HTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		t.Error("Expected the request to be sent by the custom HTTP client")
+	}))
+	defer server.Close()
+
+	var sent []string
+	client := newTestClient(server)
+	client.accessToken = &azuread.AccessToken{AccessToken: "abc123", ExpiresOn: time.Now().Add(time.Hour).Unix()}
+	client.HTTPClient = &http.Client{Transport: pipeline.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sent = append(sent, req.URL.Path)
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader("{ \"value\": [] }")), Request: req}, nil
+	})}
+
+	if _, err := client.queryTwin(context.Background(), "SELECT * FROM digitaltwins", nil, client.MaxItemsPerPage); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if len(sent) != 1 || sent[0] != "/query" {
+		t.Errorf("Expected the query to be sent by the custom HTTP client, but got %v", sent)
+	}
+}
+
+func TestClient_HTTPClient_TokenRequests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		t.Error("Expected the request to be sent by the custom HTTP client")
+	}))
+	defer server.Close()
+
+	var sent []string
+	client := newTestClient(server)
+	client.TokenCache = azuread.NewTokenCache(azuread.DefaultRefreshMargin)
+	client.HTTPClient = &http.Client{Transport: pipeline.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		sent = append(sent, req.URL.Path)
+		body := "{ \"value\": [] }"
+		if req.URL.Path == "/tenant1/oauth2/token" {
+			body = getValidAuthenticationResponse()
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(strings.NewReader(body)), Request: req}, nil
+	})}
+
+	if _, err := client.queryTwin(context.Background(), "SELECT * FROM digitaltwins", nil, client.MaxItemsPerPage); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	expected := []string{"/tenant1/oauth2/token", "/query"}
+	if !reflect.DeepEqual(sent, expected) {
+		t.Errorf("Expected the token and query requests to be sent by the custom HTTP client, but got %v", sent)
+	}
+}
+
+// countingMeter counts the instruments created from it.
+type countingMeter struct {
+	instruments int
+}
+
+func (m *countingMeter) Counter(name string) tracing.Counter {
+	m.instruments++
+	return tracing.NoopMeter.Counter(name)
+}
+
+func (m *countingMeter) Histogram(name string) tracing.Histogram {
+	m.instruments++
+	return tracing.NoopMeter.Histogram(name)
+}
+
+func TestClient_BuildsChainOnce(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		fmt.Fprint(w, "{ \"value\": [] }")
+	}))
+	defer server.Close()
+
+	meter := &countingMeter{}
+	client := newTestClient(server)
+	client.accessToken = &azuread.AccessToken{AccessToken: "abc123", ExpiresOn: time.Now().Add(time.Hour).Unix()}
+	client.Meter = meter
+
+	var created int
+	for i := 0; i < 3; i++ {
+		if _, err := client.queryTwin(context.Background(), "SELECT * FROM digitaltwins", nil, client.MaxItemsPerPage); err != nil {
+			t.Fatalf("Expected nil error, but got %v", err)
+		}
+		if i == 0 {
+			created = meter.instruments
+		}
+	}
+
+	if created == 0 || meter.instruments != created {
+		t.Errorf("Expected %d instruments to be created by the first request only, but %d were created", created, meter.instruments)
+	}
+}
@@ -0,0 +1,215 @@
+package digitaltwin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ModelData defines a DTDL model which has been uploaded to the Azure Digital Twin instance.
+type ModelData struct {
+	// Id is the DTMI of the model (e.g. dtmi:digitaltwins:rec_3_3:core:Building;1).
+	Id string `json:"id"`
+
+	// DisplayName contains the display name of the model keyed by language code.
+	DisplayName map[string]string `json:"displayName,omitempty"`
+
+	// Description contains the description of the model keyed by language code.
+	Description map[string]string `json:"description,omitempty"`
+
+	// UploadTime is when the model was uploaded to the instance.
+	UploadTime time.Time `json:"uploadTime"`
+
+	// Decommissioned is true if new twins can no longer be created using the model.
+	Decommissioned bool `json:"decommissioned"`
+
+	// Model contains the DTDL definition of the model. It is only populated when the definition
+	// has been requested.
+	Model json.RawMessage `json:"model,omitempty"`
+}
+
+// ListModelsOptions controls which models are returned by Client.ListModels.
+type ListModelsOptions struct {
+	// DependenciesFor limits the results to the given models and the models they depend on.
+	DependenciesFor []string
+
+	// IncludeModelDefinition populates the DTDL definition of each model.
+	IncludeModelDefinition bool
+}
+
+// ModelConflictError is returned when a model request conflicts with the models on the
+// instance, such as uploading a model which already exists or deleting a model which other
+// models still reference.
+type ModelConflictError struct {
+	// ModelIds contains the DTMIs the request was made for.
+	ModelIds []string
+
+	// ErrorDetail contains the error returned by the Azure Digital Twin.
+	ErrorDetail ErrorDetail
+
+	// Response contains the details of the failed response.
+	Response *ResponseError
+}
+
+func (e *ModelConflictError) Error() string {
+	return fmt.Sprintf("model conflict for %s: %s", strings.Join(e.ModelIds, ", "), e.ErrorDetail.Message)
+}
+
+func (e *ModelConflictError) Unwrap() error {
+	if e.Response == nil {
+		return nil
+	}
+	return e.Response
+}
+
+// AlreadyExists returns true if the conflict was caused by uploading a model which already
+// exists on the instance.
+func (e *ModelConflictError) AlreadyExists() bool {
+	return e.ErrorDetail.Code == "ModelAlreadyExists"
+}
+
+// CreateModels uploads a batch of DTDL model documents to the instance. Models which depend on
+// each other should be uploaded in the same batch. A ModelConflictError is returned if any of
+// the models already exist.
+func (c *Client) CreateModels(ctx context.Context, models ...json.RawMessage) ([]ModelData, error) {
+	if len(models) == 0 {
+		return nil, fmt.Errorf("at least one model must be specified")
+	}
+
+	ids := make([]string, len(models))
+	for i, m := range models {
+		var header struct {
+			Id string `json:"@id"`
+		}
+		if err := json.Unmarshal(m, &header); err != nil {
+			return nil, fmt.Errorf("model %d is not a valid DTDL document: %v", i, err)
+		}
+		ids[i] = header.Id
+	}
+
+	body, err := json.Marshal(models)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialise models: %v", err)
+	}
+
+	endpoint := c.getEndpoint(nil, "models")
+
+	resp, err := c.sendRequest(ctx, "POST", endpoint, body, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, newModelError(resp, ids...)
+	}
+
+	created, err := readResponse[[]ModelData](resp)
+	if err != nil {
+		return nil, err
+	}
+
+	return *created, nil
+}
+
+// ListModels retrieves the models on the instance, following each page of results. If options
+// is nil then all models are returned without their definitions.
+func (c *Client) ListModels(ctx context.Context, options *ListModelsOptions) ([]ModelData, error) {
+	params := url.Values{}
+	if options != nil {
+		for _, id := range options.DependenciesFor {
+			params.Add("dependenciesFor", id)
+		}
+		if options.IncludeModelDefinition {
+			params.Set("includeModelDefinition", "true")
+		}
+	}
+
+	endpoint := c.getEndpoint(params, "models")
+
+	return listAll[ModelData](ctx, c, endpoint, func(resp *http.Response) error {
+		return newModelError(resp)
+	})
+}
+
+// GetModel retrieves the model with the given DTMI, optionally including its DTDL definition.
+func (c *Client) GetModel(ctx context.Context, id string, includeModelDefinition bool) (*ModelData, error) {
+	params := url.Values{}
+	if includeModelDefinition {
+		params.Set("includeModelDefinition", "true")
+	}
+
+	endpoint := c.getEndpoint(params, "models", id)
+
+	resp, err := c.sendRequest(ctx, "GET", endpoint, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newModelError(resp, id)
+	}
+
+	return readResponse[ModelData](resp)
+}
+
+// DecommissionModel marks the model as decommissioned so that no new twins can be created
+// from it. Existing twins are unaffected.
+func (c *Client) DecommissionModel(ctx context.Context, id string) error {
+	body, err := json.Marshal(NewJsonPatch().Replace("/decommissioned", true))
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json-patch+json")
+
+	endpoint := c.getEndpoint(nil, "models", id)
+
+	resp, err := c.sendRequest(ctx, "PATCH", endpoint, body, header)
+	if err != nil {
+		return err
+	}
+	defer c.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return newModelError(resp, id)
+	}
+
+	return nil
+}
+
+// DeleteModel deletes the model from the instance. A ModelConflictError is returned if other
+// models still depend on it.
+func (c *Client) DeleteModel(ctx context.Context, id string) error {
+	endpoint := c.getEndpoint(nil, "models", id)
+
+	resp, err := c.sendRequest(ctx, "DELETE", endpoint, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer c.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return newModelError(resp, id)
+	}
+
+	return nil
+}
+
+// newModelError creates the error for a failed model request, using a ModelConflictError if
+// the request conflicted with the models on the instance.
+func newModelError(resp *http.Response, ids ...string) error {
+	responseError := newResponseError(resp, "Models", strings.Join(ids, ", "))
+
+	if resp.StatusCode == http.StatusConflict {
+		return &ModelConflictError{ModelIds: ids, ErrorDetail: responseError.ErrorDetail, Response: responseError}
+	}
+
+	return responseError
+}
@@ -0,0 +1,191 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/models/rec33"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testBuildingModel = `{ "@id": "dtmi:digitaltwins:rec_3_3:core:Building;1", "@type": "Interface", "@context": "dtmi:dtdl:context;2", "displayName": { "en": "Building" } }`
+
+// newModelServer creates a test server for the model APIs which only knows the rec33 Building
+// model, rejecting uploads of it as already existing.
+func newModelServer(requests *[]*http.Request, bodies *[]string) *httptest.Server {
+	buildingModel := rec33.Building{}.Model()
+	modelData := fmt.Sprintf("{ \"id\": %q, \"displayName\": { \"en\": \"Building\" }, \"uploadTime\": \"2022-06-22T09:09:17Z\", \"decommissioned\": false, \"model\": %s }", buildingModel, testBuildingModel)
+
+	return newRecordingServer(requests, bodies, func(w http.ResponseWriter, req *http.Request, body string) {
+		switch {
+		case req.URL.Path == "/models" && req.Method == "POST":
+			if strings.Contains(body, buildingModel) {
+				w.WriteHeader(http.StatusConflict)
+				fmt.Fprint(w, "{ \"error\": { \"code\": \"ModelAlreadyExists\", \"message\": \"Model already exists\" } }")
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, "[ { \"id\": \"dtmi:example:Thing;1\", \"uploadTime\": \"2022-06-22T09:09:17Z\" } ]")
+		case req.URL.Path == "/models":
+			fmt.Fprintf(w, "{ \"value\": [ %s ] }", modelData)
+		case req.URL.Path == "/models/"+buildingModel:
+			if req.Method == "GET" {
+				fmt.Fprint(w, modelData)
+			} else {
+				w.WriteHeader(http.StatusNoContent)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "{ \"error\": { \"code\": \"ModelNotFound\", \"message\": \"Model not found\" } }")
+		}
+	})
+}
+
+func TestClient_CreateModels(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newModelServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	thing := json.RawMessage(`{ "@id": "dtmi:example:Thing;1", "@type": "Interface", "@context": "dtmi:dtdl:context;2" }`)
+
+	created, err := client.CreateModels(context.Background(), thing)
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if len(created) != 1 || created[0].Id != "dtmi:example:Thing;1" {
+		t.Errorf("Unexpected models returned: %v", created)
+	}
+
+	var uploaded []map[string]any
+	if err = json.Unmarshal([]byte(bodies[0]), &uploaded); err != nil || len(uploaded) != 1 {
+		t.Errorf("Expected an array containing one model to be uploaded, but got '%s'", bodies[0])
+	}
+}
+
+func TestClient_CreateModels_AlreadyExists(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newModelServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	buildingModel := rec33.Building{}.Model()
+
+	_, err := client.CreateModels(context.Background(), json.RawMessage(testBuildingModel))
+
+	var conflict *ModelConflictError
+	if !errors.As(err, &conflict) {
+		t.Logf("Expected ModelConflictError, but got %v", err)
+		t.FailNow()
+	}
+
+	if !conflict.AlreadyExists() || conflict.ModelIds[0] != buildingModel {
+		t.Errorf("Unexpected conflict error: %v", conflict)
+	}
+}
+
+func TestClient_CreateModels_InvalidDocument(t *testing.T) {
+	client := NewClient(nil, nil)
+
+	_, err := client.CreateModels(context.Background(), json.RawMessage(`[ "not a model" ]`))
+	if err == nil || !strings.Contains(err.Error(), "model 0 is not a valid DTDL document") {
+		t.Errorf("Expected invalid document error, but got %v", err)
+	}
+}
+
+func TestClient_ListModels(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newModelServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	options := ListModelsOptions{
+		DependenciesFor:        []string{"dtmi:digitaltwins:rec_3_3:core:Building;1", "dtmi:digitaltwins:rec_3_3:core:Level;1"},
+		IncludeModelDefinition: true,
+	}
+
+	listed, err := client.ListModels(context.Background(), &options)
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if len(listed) != 1 || listed[0].DisplayName["en"] != "Building" || len(listed[0].Model) == 0 {
+		t.Errorf("Unexpected models returned: %v", listed)
+	}
+
+	query := requests[0].URL.Query()
+	if len(query["dependenciesFor"]) != 2 || query.Get("includeModelDefinition") != "true" {
+		t.Errorf("Expected dependency and definition options in query, but got '%s'", requests[0].URL.RawQuery)
+	}
+}
+
+func TestClient_GetModel(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newModelServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	buildingModel := rec33.Building{}.Model()
+
+	model, err := client.GetModel(context.Background(), buildingModel, false)
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if model.Id != buildingModel || model.UploadTime.Year() != 2022 {
+		t.Errorf("Unexpected model returned: %v", model)
+	}
+
+	if requests[0].URL.Query().Has("includeModelDefinition") {
+		t.Error("Expected the model definition not to be requested")
+	}
+}
+
+func TestClient_DecommissionModel(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newModelServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	if err := client.DecommissionModel(context.Background(), rec33.Building{}.Model()); err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	expectedBody := `[{"op":"replace","path":"/decommissioned","value":true}]`
+	if requests[0].Method != "PATCH" || bodies[0] != expectedBody {
+		t.Errorf("Expected PATCH with body '%s', but got %s with '%s'", expectedBody, requests[0].Method, bodies[0])
+	}
+}
+
+func TestClient_DeleteModel_NotFound(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newModelServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	err := client.DeleteModel(context.Background(), "dtmi:example:Missing;1")
+	if err == nil || !strings.Contains(err.Error(), "Model not found") {
+		t.Errorf("Expected not found error, but got %v", err)
+	}
+}
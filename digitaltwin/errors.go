@@ -0,0 +1,80 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/retry"
+	"fmt"
+	"net/http"
+)
+
+// requestIdHeader is the response header containing the id Azure assigned to the request,
+// which is needed when raising a support request.
+const requestIdHeader = "x-ms-request-id"
+
+// ResponseError is returned when the Azure Digital Twin responds to a request with a
+// non-success status code. More specific errors, such as PreconditionFailedError, wrap a
+// ResponseError so that it can always be retrieved using errors.As.
+type ResponseError struct {
+	// StatusCode of the response.
+	StatusCode int
+
+	// ErrorDetail contains the error returned by the Azure Digital Twin, which is empty if the
+	// response did not contain one.
+	ErrorDetail ErrorDetail
+
+	// RequestId is the id Azure assigned to the request.
+	RequestId string
+
+	// Method and URL of the request which failed.
+	Method string
+	URL    string
+
+	// Resource is the type of resource the request was for, such as Twin or EventRoute, and Id
+	// identifies it. Both are empty for a query.
+	Resource string
+	Id       string
+
+	// Query is the text of the query which failed, empty if the request was not a query.
+	Query string
+}
+
+func (e *ResponseError) Error() string {
+	if e.Query != "" {
+		return fmt.Sprintf("non-success status code returned: %d\nQuery: %s\n%s", e.StatusCode, e.Query, e.ErrorDetail.Message)
+	}
+	return fmt.Sprintf("non-success status code returned: %d\n%s: %s\n%s", e.StatusCode, e.Resource, e.Id, e.ErrorDetail.Message)
+}
+
+// IsRetryable returns true if the request failed because of a transient error, such as
+// throttling, and may succeed if it is sent again.
+func (e *ResponseError) IsRetryable() bool {
+	return retry.DefaultPolicy().IsRetryableStatus(e.StatusCode)
+}
+
+// IsNotFound returns true if the resource the request was for does not exist.
+func (e *ResponseError) IsNotFound() bool {
+	return e.StatusCode == http.StatusNotFound
+}
+
+// IsPreconditionFailed returns true if the request was rejected because of its ETag.
+func (e *ResponseError) IsPreconditionFailed() bool {
+	return e.StatusCode == http.StatusPreconditionFailed
+}
+
+// newResponseError creates the error for a non-success response, reading the Azure Digital
+// Twin error from its body.
+func newResponseError(resp *http.Response, resource string, id string) *ResponseError {
+	responseError := &ResponseError{
+		StatusCode:  resp.StatusCode,
+		ErrorDetail: readErrorDetail(resp),
+		RequestId:   resp.Header.Get(requestIdHeader),
+		Resource:    resource,
+		Id:          id,
+	}
+
+	if resp.Request != nil {
+		responseError.Method = resp.Request.Method
+		responseError.URL = resp.Request.URL.String()
+	}
+
+	return responseError
+}
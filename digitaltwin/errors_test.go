@@ -0,0 +1,99 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/models"
+	"azure-adt-example/digitaltwin/models/rec33"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestResponseError_Query(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.RequestURI == "/tenant1/oauth2/token" && req.Method == "POST" {
+			fmt.Fprint(w, getValidAuthenticationResponse())
+			return
+		}
+
+		w.Header().Set("x-ms-request-id", "request1")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{ "error": {
+			"code": "BadRequest",
+			"message": "Invalid query",
+			"details": [ { "code": "QueryParseError", "message": "Unexpected token" } ],
+			"innererror": { "code": "InvalidQuery", "innererror": { "code": "QuerySyntaxError" } }
+		} }`)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+	_, err := client.queryTwin(context.Background(), "SELECT FROM", nil, 1)
+
+	var responseError *ResponseError
+	if !errors.As(err, &responseError) {
+		t.Fatalf("Expected a ResponseError, but got %v", err)
+	}
+
+	if responseError.StatusCode != http.StatusBadRequest || responseError.RequestId != "request1" || responseError.Query != "SELECT FROM" || responseError.Method != "POST" {
+		t.Errorf("Unexpected error %+v", responseError)
+	}
+
+	expectedDetails := []ErrorDetail{{Code: "QueryParseError", Message: "Unexpected token"}}
+	if responseError.ErrorDetail.Code != "BadRequest" || !reflect.DeepEqual(responseError.ErrorDetail.Details, expectedDetails) {
+		t.Errorf("Unexpected error detail %+v", responseError.ErrorDetail)
+	}
+	if code := responseError.ErrorDetail.InnermostCode(); code != "QuerySyntaxError" {
+		t.Errorf("Expected innermost code QuerySyntaxError, but got %s", code)
+	}
+
+	if responseError.IsRetryable() || responseError.IsNotFound() || responseError.IsPreconditionFailed() {
+		t.Errorf("Expected a bad request to not be retryable, not found or a failed precondition")
+	}
+}
+
+func TestResponseError_Resource(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newTwinServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	_, err := GetTwin[rec33.Building](context.Background(), client, "missing01")
+	var responseError *ResponseError
+	if !errors.As(err, &responseError) || !responseError.IsNotFound() {
+		t.Fatalf("Expected a not found ResponseError, but got %v", err)
+	}
+	if responseError.Resource != "Twin" || responseError.Id != "missing01" || responseError.ErrorDetail.Code != "DigitalTwinNotFound" {
+		t.Errorf("Unexpected error %+v", responseError)
+	}
+
+	twin := rec33.Building{GenericModel: models.GenericModel{ExternalId: "building01", ETag: "W/\"stale\""}}
+	err = DeleteTwin(context.Background(), client, twin)
+	var preconditionError *PreconditionFailedError
+	if !errors.As(err, &preconditionError) {
+		t.Fatalf("Expected a PreconditionFailedError, but got %v", err)
+	}
+	if !errors.As(err, &responseError) || !responseError.IsPreconditionFailed() {
+		t.Errorf("Expected the PreconditionFailedError to wrap a ResponseError, but got %v", err)
+	}
+}
+
+func TestResponseError_IsRetryable(t *testing.T) {
+	for status, expected := range map[int]bool{
+		http.StatusTooManyRequests:     true,
+		http.StatusServiceUnavailable:  true,
+		http.StatusInternalServerError: true,
+		http.StatusBadRequest:          false,
+		http.StatusNotFound:            false,
+	} {
+		responseError := ResponseError{StatusCode: status}
+		if actual := responseError.IsRetryable(); actual != expected {
+			t.Errorf("Expected retryable %v for %d, but got %v", expected, status, actual)
+		}
+	}
+}
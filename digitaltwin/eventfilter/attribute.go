@@ -0,0 +1,58 @@
+package eventfilter
+
+import (
+	"log"
+	"strings"
+)
+
+// Attribute defines the event attributes which an event route filter can test.
+type Attribute int
+
+const (
+	Type Attribute = iota + 1
+	Source
+	Subject
+	Id
+	SpecVersion
+	DataSchema
+	DataContentType
+)
+
+func (a Attribute) String() string {
+	attributes := []string{"type", "source", "subject", "id", "specversion", "dataschema", "datacontenttype"}
+	if !a.IsValid() {
+		log.Fatalf("%d is not a valid attribute type", a)
+	}
+	return attributes[a-1]
+}
+
+func (a Attribute) IsValid() bool {
+	switch a {
+	case Type, Source, Subject, Id, SpecVersion, DataSchema, DataContentType:
+		return true
+	}
+	return false
+}
+
+// parseAttribute finds the Attribute with the given name, ignoring case.
+func parseAttribute(name string) (Attribute, bool) {
+	for a := Type; a <= DataContentType; a++ {
+		if strings.EqualFold(a.String(), name) {
+			return a, true
+		}
+	}
+	return 0, false
+}
+
+// EventType defines the types of event which Azure Digital Twin publishes to event routes.
+type EventType string
+
+const (
+	TwinCreate         EventType = "Microsoft.DigitalTwins.Twin.Create"
+	TwinUpdate         EventType = "Microsoft.DigitalTwins.Twin.Update"
+	TwinDelete         EventType = "Microsoft.DigitalTwins.Twin.Delete"
+	RelationshipCreate EventType = "Microsoft.DigitalTwins.Relationship.Create"
+	RelationshipUpdate EventType = "Microsoft.DigitalTwins.Relationship.Update"
+	RelationshipDelete EventType = "Microsoft.DigitalTwins.Relationship.Delete"
+	Telemetry          EventType = "microsoft.iot.telemetry"
+)
@@ -0,0 +1,140 @@
+package eventfilter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Filter defines an expression which decides if an event is sent to an event route endpoint.
+type Filter interface {
+	Expression() string
+}
+
+// comparison compares an event attribute to a value (e.g. type = 'Microsoft.DigitalTwins.Twin.Update').
+type comparison struct {
+	attribute Attribute
+	operator  string
+	value     string
+}
+
+func (c comparison) Expression() string {
+	return fmt.Sprintf("%s %s %s", c.attribute, c.operator, quote(c.value))
+}
+
+// function applies a string function to an event attribute (e.g. STARTS_WITH(subject, 'building')).
+type function struct {
+	name      string
+	attribute Attribute
+	value     string
+}
+
+func (f function) Expression() string {
+	return fmt.Sprintf("%s(%s, %s)", f.name, f.attribute, quote(f.value))
+}
+
+// logical combines filters using AND, OR or NOT.
+type logical struct {
+	operator string
+	filters  []Filter
+}
+
+func (l logical) Expression() string {
+	expressions := make([]string, len(l.filters))
+	for i, f := range l.filters {
+		expressions[i] = f.Expression()
+	}
+
+	if l.operator == "NOT" {
+		return fmt.Sprintf("NOT (%s)", strings.Join(expressions, ""))
+	}
+
+	return fmt.Sprintf("(%s)", strings.Join(expressions, fmt.Sprintf(" %s ", l.operator)))
+}
+
+// literal is a filter which either matches every event or none.
+type literal bool
+
+func (l literal) Expression() string {
+	if l {
+		return "true"
+	}
+	return "false"
+}
+
+// All creates a filter which sends every event to the endpoint.
+func All() Filter {
+	return literal(true)
+}
+
+// Equals creates a filter matching events where the attribute equals the value.
+func Equals(attribute Attribute, value string) Filter {
+	return comparison{attribute: attribute, operator: "=", value: value}
+}
+
+// NotEquals creates a filter matching events where the attribute does not equal the value.
+func NotEquals(attribute Attribute, value string) Filter {
+	return comparison{attribute: attribute, operator: "!=", value: value}
+}
+
+// OfType creates a filter matching events of any of the given types.
+func OfType(eventTypes ...EventType) Filter {
+	if len(eventTypes) == 1 {
+		return Equals(Type, string(eventTypes[0]))
+	}
+
+	filters := make([]Filter, len(eventTypes))
+	for i, et := range eventTypes {
+		filters[i] = Equals(Type, string(et))
+	}
+
+	return Or(filters...)
+}
+
+// StartsWith creates a filter matching events where the attribute starts with the value.
+func StartsWith(attribute Attribute, value string) Filter {
+	return function{name: "STARTS_WITH", attribute: attribute, value: value}
+}
+
+// EndsWith creates a filter matching events where the attribute ends with the value.
+func EndsWith(attribute Attribute, value string) Filter {
+	return function{name: "ENDS_WITH", attribute: attribute, value: value}
+}
+
+// Contains creates a filter matching events where the attribute contains the value.
+func Contains(attribute Attribute, value string) Filter {
+	return function{name: "CONTAINS", attribute: attribute, value: value}
+}
+
+// And creates a filter matching events which match all the filters.
+func And(filters ...Filter) Filter {
+	return logical{operator: "AND", filters: filters}
+}
+
+// Or creates a filter matching events which match any of the filters.
+func Or(filters ...Filter) Filter {
+	return logical{operator: "OR", filters: filters}
+}
+
+// Not creates a filter matching events which do not match the filter.
+func Not(filter Filter) Filter {
+	return logical{operator: "NOT", filters: []Filter{filter}}
+}
+
+// Build generates the expression for the filter and checks that it is valid.
+func Build(filter Filter) (string, error) {
+	if filter == nil {
+		return "", fmt.Errorf("a filter must be specified")
+	}
+
+	expression := filter.Expression()
+	if err := Validate(expression); err != nil {
+		return "", err
+	}
+
+	return expression, nil
+}
+
+// quote wraps a value in single quotes, escaping any single quotes in the value.
+func quote(value string) string {
+	return fmt.Sprintf("'%s'", strings.ReplaceAll(value, "'", "''"))
+}
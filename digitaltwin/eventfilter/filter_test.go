@@ -0,0 +1,82 @@
+package eventfilter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected string
+	}{
+		{"all events", All(), "true"},
+		{"single type", OfType(TwinUpdate), "type = 'Microsoft.DigitalTwins.Twin.Update'"},
+		{"multiple types", OfType(TwinCreate, TwinDelete), "(type = 'Microsoft.DigitalTwins.Twin.Create' OR type = 'Microsoft.DigitalTwins.Twin.Delete')"},
+		{"function", StartsWith(Subject, "building"), "STARTS_WITH(subject, 'building')"},
+		{"combined", And(OfType(Telemetry), Not(EndsWith(Source, "test"))), "(type = 'microsoft.iot.telemetry' AND NOT (ENDS_WITH(source, 'test')))"},
+		{"quoted value", NotEquals(Subject, "o'brien"), "subject != 'o''brien'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expression, err := Build(test.filter)
+			if err != nil {
+				t.Fatalf("Expected nil error, but got %v", err)
+			}
+
+			if expression != test.expected {
+				t.Errorf("Expected '%s', but got '%s'", test.expected, expression)
+			}
+		})
+	}
+}
+
+func TestBuild_NilFilter(t *testing.T) {
+	if _, err := Build(nil); err == nil {
+		t.Error("Expected an error for a nil filter")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := []string{
+		"true",
+		"False",
+		"type = 'Microsoft.DigitalTwins.Twin.Update'",
+		"Type <> 'a' and not (subject = 'b' OR contains(source, 'c'))",
+		"specversion >= '1.0'",
+	}
+
+	for _, filter := range valid {
+		if err := Validate(filter); err != nil {
+			t.Errorf("Expected '%s' to be valid, but got %v", filter, err)
+		}
+	}
+}
+
+func TestValidate_Invalid(t *testing.T) {
+	tests := []struct {
+		filter  string
+		message string
+	}{
+		{"", "expected a condition"},
+		{"type = 'unterminated", "unterminated string"},
+		{"type == 'a'", "invalid operator '=='"},
+		{"colour = 'red'", "'colour' at position 0 is not a filterable event attribute"},
+		{"type = 'a' AND", "expected a condition"},
+		{"(type = 'a'", "expected ')'"},
+		{"type 'a'", "expected a comparison operator"},
+		{"type = a", "expected a quoted value"},
+		{"STARTS_WITH(subject 'a')", "expected ','"},
+		{"type = 'a' subject = 'b'", "unexpected 'subject' at position 11"},
+		{"type = 'a' & subject = 'b'", "unexpected character '&'"},
+	}
+
+	for _, test := range tests {
+		err := Validate(test.filter)
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Errorf("Expected error containing '%s' for '%s', but got %v", test.message, test.filter, err)
+		}
+	}
+}
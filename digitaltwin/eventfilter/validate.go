@@ -0,0 +1,272 @@
+package eventfilter
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	identifierToken tokenKind = iota + 1
+	stringToken
+	operatorToken
+	openToken
+	closeToken
+	commaToken
+	endToken
+)
+
+// token is a single lexical element of a filter expression.
+type token struct {
+	kind     tokenKind
+	value    string
+	position int
+}
+
+var (
+	comparisonOperators = []string{"=", "!=", "<>", "<=", ">=", "<", ">"}
+	filterFunctions     = []string{"STARTS_WITH", "ENDS_WITH", "CONTAINS"}
+)
+
+// Validate checks that the filter expression is one Azure Digital Twin will accept, returning
+// an error describing the first problem found.
+func Validate(filter string) error {
+	tokens, err := tokenize(filter)
+	if err != nil {
+		return err
+	}
+
+	p := parser{tokens: tokens}
+	if err = p.parseOr(); err != nil {
+		return err
+	}
+
+	if next := p.peek(); next.kind != endToken {
+		return fmt.Errorf("unexpected '%s' at position %d", next.value, next.position)
+	}
+
+	return nil
+}
+
+// tokenize splits the filter expression into tokens.
+func tokenize(filter string) ([]token, error) {
+	tokens := make([]token, 0)
+	runes := []rune(filter)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{openToken, "(", i})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{closeToken, ")", i})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{commaToken, ",", i})
+			i++
+		case r == '\'':
+			start := i
+			var value strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						value.WriteRune('\'')
+						i += 2
+						continue
+					}
+					closed = true
+					i++
+					break
+				}
+				value.WriteRune(runes[i])
+				i++
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string starting at position %d", start)
+			}
+			tokens = append(tokens, token{stringToken, value.String(), start})
+		case strings.ContainsRune("=!<>", r):
+			start := i
+			for i < len(runes) && strings.ContainsRune("=!<>", runes[i]) {
+				i++
+			}
+			op := string(runes[start:i])
+			if !contains(comparisonOperators, op) {
+				return nil, fmt.Errorf("invalid operator '%s' at position %d", op, start)
+			}
+			tokens = append(tokens, token{operatorToken, op, start})
+		case unicode.IsLetter(r) || r == '_' || r == '$':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '$' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{identifierToken, string(runes[start:i]), start})
+		default:
+			return nil, fmt.Errorf("unexpected character '%c' at position %d", r, i)
+		}
+	}
+
+	return append(tokens, token{endToken, "end of filter", len(runes)}), nil
+}
+
+// parser is a recursive descent parser for the filter grammar:
+//
+//	or         := and { OR and }
+//	and        := unary { AND unary }
+//	unary      := NOT unary | primary
+//	primary    := '(' or ')' | TRUE | FALSE | function | comparison
+//	function   := (STARTS_WITH | ENDS_WITH | CONTAINS) '(' attribute ',' string ')'
+//	comparison := attribute operator string
+type parser struct {
+	tokens   []token
+	position int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.position]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.position]
+	if t.kind != endToken {
+		p.position++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind, description string) (token, error) {
+	t := p.next()
+	if t.kind != kind {
+		return t, fmt.Errorf("expected %s but found '%s' at position %d", description, t.value, t.position)
+	}
+	return t, nil
+}
+
+func (p *parser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == identifierToken && strings.EqualFold(t.value, keyword)
+}
+
+func (p *parser) parseOr() error {
+	if err := p.parseAnd(); err != nil {
+		return err
+	}
+
+	for p.isKeyword("OR") {
+		p.next()
+		if err := p.parseAnd(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) parseAnd() error {
+	if err := p.parseUnary(); err != nil {
+		return err
+	}
+
+	for p.isKeyword("AND") {
+		p.next()
+		if err := p.parseUnary(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *parser) parseUnary() error {
+	if p.isKeyword("NOT") {
+		p.next()
+		return p.parseUnary()
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() error {
+	t := p.next()
+
+	switch t.kind {
+	case openToken:
+		if err := p.parseOr(); err != nil {
+			return err
+		}
+		_, err := p.expect(closeToken, "')'")
+		return err
+	case identifierToken:
+		if strings.EqualFold(t.value, "true") || strings.EqualFold(t.value, "false") {
+			return nil
+		}
+
+		if contains(filterFunctions, strings.ToUpper(t.value)) {
+			return p.parseFunctionArguments()
+		}
+
+		if err := checkAttribute(t); err != nil {
+			return err
+		}
+
+		if _, err := p.expect(operatorToken, "a comparison operator"); err != nil {
+			return err
+		}
+
+		_, err := p.expect(stringToken, "a quoted value")
+		return err
+	default:
+		return fmt.Errorf("expected a condition but found '%s' at position %d", t.value, t.position)
+	}
+}
+
+func (p *parser) parseFunctionArguments() error {
+	if _, err := p.expect(openToken, "'('"); err != nil {
+		return err
+	}
+
+	attribute, err := p.expect(identifierToken, "an attribute")
+	if err != nil {
+		return err
+	}
+
+	if err = checkAttribute(attribute); err != nil {
+		return err
+	}
+
+	if _, err = p.expect(commaToken, "','"); err != nil {
+		return err
+	}
+
+	if _, err = p.expect(stringToken, "a quoted value"); err != nil {
+		return err
+	}
+
+	_, err = p.expect(closeToken, "')'")
+	return err
+}
+
+// checkAttribute makes sure the identifier is an attribute which can be filtered on.
+func checkAttribute(t token) error {
+	if _, ok := parseAttribute(t.value); !ok {
+		return fmt.Errorf("'%s' at position %d is not a filterable event attribute", t.value, t.position)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,106 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/eventfilter"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// EventRoute defines a route which sends events matching the filter to an endpoint configured
+// on the Azure Digital Twin instance.
+type EventRoute struct {
+	Id           string `json:"id,omitempty"`
+	EndpointName string `json:"endpointName"`
+	Filter       string `json:"filter"`
+}
+
+// NewEventRoute creates an event route using a filter built with the eventfilter package.
+func NewEventRoute(id string, endpointName string, filter eventfilter.Filter) (*EventRoute, error) {
+	expression, err := eventfilter.Build(filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid filter for event route %s: %v", id, err)
+	}
+
+	return &EventRoute{Id: id, EndpointName: endpointName, Filter: expression}, nil
+}
+
+// ListEventRoutes retrieves all the event routes on the instance, following each page of results.
+func (c *Client) ListEventRoutes(ctx context.Context) ([]EventRoute, error) {
+	endpoint := c.getEndpoint(nil, "eventroutes")
+
+	return listAll[EventRoute](ctx, c, endpoint, func(resp *http.Response) error {
+		return newResourceError(resp, "EventRoute", "", "")
+	})
+}
+
+// GetEventRoute retrieves the event route with the given id.
+func (c *Client) GetEventRoute(ctx context.Context, id string) (*EventRoute, error) {
+	endpoint := c.getEndpoint(nil, "eventroutes", id)
+
+	resp, err := c.sendRequest(ctx, "GET", endpoint, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newResourceError(resp, "EventRoute", id, "")
+	}
+
+	return readResponse[EventRoute](resp)
+}
+
+// CreateOrReplaceEventRoute creates the event route, or replaces it if a route with the same
+// id already exists. The filter is validated before the request is sent, and an empty filter
+// is sent as "true" so that every event is routed to the endpoint.
+func (c *Client) CreateOrReplaceEventRoute(ctx context.Context, route EventRoute) error {
+	if route.Id == "" || route.EndpointName == "" {
+		return fmt.Errorf("event route must have an id and endpoint name")
+	}
+
+	if route.Filter == "" {
+		route.Filter = eventfilter.All().Expression()
+	}
+
+	if err := eventfilter.Validate(route.Filter); err != nil {
+		return fmt.Errorf("invalid filter for event route %s: %v", route.Id, err)
+	}
+
+	body, err := json.Marshal(EventRoute{EndpointName: route.EndpointName, Filter: route.Filter})
+	if err != nil {
+		return fmt.Errorf("unable to serialise event route %s: %v", route.Id, err)
+	}
+
+	endpoint := c.getEndpoint(nil, "eventroutes", route.Id)
+
+	resp, err := c.sendRequest(ctx, "PUT", endpoint, body, nil)
+	if err != nil {
+		return err
+	}
+	defer c.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return newResourceError(resp, "EventRoute", route.Id, "")
+	}
+
+	return nil
+}
+
+// DeleteEventRoute deletes the event route with the given id.
+func (c *Client) DeleteEventRoute(ctx context.Context, id string) error {
+	endpoint := c.getEndpoint(nil, "eventroutes", id)
+
+	resp, err := c.sendRequest(ctx, "DELETE", endpoint, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer c.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return newResourceError(resp, "EventRoute", id, "")
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/eventfilter"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testEventRoute = `{ "id": "twin-updates", "endpointName": "eventgrid", "filter": "type = 'Microsoft.DigitalTwins.Twin.Update'" }`
+
+// newEventRouteServer creates a test server for the event route APIs which only knows the
+// "twin-updates" route.
+func newEventRouteServer(requests *[]*http.Request, bodies *[]string) *httptest.Server {
+	return newRecordingServer(requests, bodies, func(w http.ResponseWriter, req *http.Request, body string) {
+		switch {
+		case req.URL.Path == "/eventroutes":
+			fmt.Fprintf(w, "{ \"value\": [ %s ] }", testEventRoute)
+		case req.URL.Path == "/eventroutes/twin-updates" && req.Method == "GET":
+			fmt.Fprint(w, testEventRoute)
+		case strings.HasPrefix(req.URL.Path, "/eventroutes/") && req.Method != "GET":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "{ \"error\": { \"code\": \"EventRouteNotFound\", \"message\": \"There is no event route with the ID\" } }")
+		}
+	})
+}
+
+func TestClient_ListEventRoutes(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newEventRouteServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	routes, err := client.ListEventRoutes(context.Background())
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if len(routes) != 1 || routes[0].Id != "twin-updates" || routes[0].EndpointName != "eventgrid" {
+		t.Errorf("Unexpected event routes %v", routes)
+	}
+}
+
+func TestClient_GetEventRoute(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newEventRouteServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	route, err := client.GetEventRoute(context.Background(), "twin-updates")
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if route.Filter != "type = 'Microsoft.DigitalTwins.Twin.Update'" {
+		t.Errorf("Unexpected filter '%s'", route.Filter)
+	}
+
+	_, err = client.GetEventRoute(context.Background(), "missing")
+	if err == nil || !strings.Contains(err.Error(), "EventRoute: missing") {
+		t.Errorf("Expected not found error for missing route, but got %v", err)
+	}
+}
+
+func TestClient_CreateOrReplaceEventRoute(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newEventRouteServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	route, err := NewEventRoute("building-events", "eventhub", eventfilter.StartsWith(eventfilter.Subject, "building"))
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if err = client.CreateOrReplaceEventRoute(context.Background(), *route); err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if requests[0].Method != "PUT" || requests[0].URL.Path != "/eventroutes/building-events" {
+		t.Errorf("Unexpected request %s %s", requests[0].Method, requests[0].URL.Path)
+	}
+
+	var sent map[string]string
+	if err = json.Unmarshal([]byte(bodies[0]), &sent); err != nil {
+		t.Fatalf("Unable to parse request body: %v", err)
+	}
+
+	if _, ok := sent["id"]; ok || sent["endpointName"] != "eventhub" || sent["filter"] != "STARTS_WITH(subject, 'building')" {
+		t.Errorf("Unexpected request body '%s'", bodies[0])
+	}
+}
+
+func TestClient_CreateOrReplaceEventRoute_DefaultFilter(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newEventRouteServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	err := client.CreateOrReplaceEventRoute(context.Background(), EventRoute{Id: "everything", EndpointName: "eventhub"})
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if !strings.Contains(bodies[0], `"filter":"true"`) {
+		t.Errorf("Expected default filter of true, but got '%s'", bodies[0])
+	}
+}
+
+func TestClient_CreateOrReplaceEventRoute_InvalidFilter(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newEventRouteServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	err := client.CreateOrReplaceEventRoute(context.Background(), EventRoute{Id: "bad", EndpointName: "eventhub", Filter: "type = "})
+	if err == nil || !strings.Contains(err.Error(), "invalid filter for event route bad") {
+		t.Errorf("Expected invalid filter error, but got %v", err)
+	}
+
+	if len(requests) != 0 {
+		t.Errorf("Expected no requests to be sent, but %d were", len(requests))
+	}
+}
+
+func TestClient_DeleteEventRoute(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newEventRouteServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	if err := client.DeleteEventRoute(context.Background(), "twin-updates"); err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if requests[0].Method != "DELETE" || requests[0].URL.Path != "/eventroutes/twin-updates" {
+		t.Errorf("Unexpected request %s %s", requests[0].Method, requests[0].URL.Path)
+	}
+}
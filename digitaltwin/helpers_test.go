@@ -0,0 +1,51 @@
+package digitaltwin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+)
+
+// newRecordingServer creates a test server which answers token requests, then records every
+// other request and its body before passing it to the routes of the API being tested.
+func newRecordingServer(requests *[]*http.Request, bodies *[]string, routes func(w http.ResponseWriter, req *http.Request, body string)) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.RequestURI == "/tenant1/oauth2/token" && req.Method == "POST" {
+			fmt.Fprint(w, getValidAuthenticationResponse())
+			return
+		}
+
+		body, _ := ioutil.ReadAll(req.Body)
+		*requests = append(*requests, req)
+		*bodies = append(*bodies, string(body))
+
+		routes(w, req, string(body))
+	}))
+}
+
+// newQueryServer creates a test server which answers token requests, then records the query of
+// every other request and returns the body.
+func newQueryServer(queries *[]string, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.RequestURI == "/tenant1/oauth2/token" && req.Method == "POST" {
+			fmt.Fprint(w, getValidAuthenticationResponse())
+			return
+		}
+
+		var request struct {
+			Query string `json:"query"`
+		}
+		_ = json.NewDecoder(req.Body).Decode(&request)
+		*queries = append(*queries, request.Query)
+
+		fmt.Fprint(w, body)
+	}))
+}
+
+// queryResultBody is the body of a page of query results, with each row given as its columns.
+type queryResultBody struct {
+	Results           []map[string]json.RawMessage `json:"value"`
+	ContinuationToken string                       `json:"continuationToken"`
+}
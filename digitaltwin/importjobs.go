@@ -0,0 +1,173 @@
+package digitaltwin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// importJobsApiVersion is the first version of the Azure Digital Twin API supporting import jobs.
+const importJobsApiVersion = "2023-10-31"
+
+// ImportJobStatus defines the states an import job moves through.
+type ImportJobStatus string
+
+const (
+	ImportJobNotStarted ImportJobStatus = "notstarted"
+	ImportJobRunning    ImportJobStatus = "running"
+	ImportJobFailed     ImportJobStatus = "failed"
+	ImportJobSucceeded  ImportJobStatus = "succeeded"
+	ImportJobCancelling ImportJobStatus = "cancelling"
+	ImportJobCancelled  ImportJobStatus = "cancelled"
+)
+
+// IsFinished is true once the import job has stopped and will not change status again.
+func (s ImportJobStatus) IsFinished() bool {
+	switch s {
+	case ImportJobFailed, ImportJobSucceeded, ImportJobCancelled:
+		return true
+	}
+	return false
+}
+
+// ImportJob defines a job which imports the models, twins and relationships in an import
+// file, such as one generated using the bulkimport package, from blob storage.
+type ImportJob struct {
+	Id string `json:"id,omitempty"`
+
+	// InputBlobUri is the location of the import file.
+	InputBlobUri string `json:"inputBlobUri"`
+
+	// OutputBlobUri is the location the job writes its log to.
+	OutputBlobUri string `json:"outputBlobUri"`
+
+	Status             ImportJobStatus `json:"status,omitempty"`
+	Error              *ErrorDetail    `json:"error,omitempty"`
+	CreatedDateTime    *time.Time      `json:"createdDateTime,omitempty"`
+	LastActionDateTime *time.Time      `json:"lastActionDateTime,omitempty"`
+	FinishedDateTime   *time.Time      `json:"finishedDateTime,omitempty"`
+	PurgeDateTime      *time.Time      `json:"purgeDateTime,omitempty"`
+}
+
+// getImportJobEndpoint generates the URL for the import jobs API, which requires a later API
+// version than the rest of the Client.
+func (c *Client) getImportJobEndpoint(segments ...string) string {
+	params := url.Values{}
+	params.Set("api-version", importJobsApiVersion)
+
+	return c.getEndpoint(params, append([]string{"jobs", "imports"}, segments...)...)
+}
+
+// CreateImportJob starts a job importing the file at the input blob URI, writing the job log
+// to the output blob URI. The Azure Digital Twin instance must have access to both blobs.
+func (c *Client) CreateImportJob(ctx context.Context, id string, inputBlobUri string, outputBlobUri string) (*ImportJob, error) {
+	if id == "" || inputBlobUri == "" || outputBlobUri == "" {
+		return nil, fmt.Errorf("import job must have an id, input blob uri and output blob uri")
+	}
+
+	body, err := json.Marshal(ImportJob{InputBlobUri: inputBlobUri, OutputBlobUri: outputBlobUri})
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialise import job %s: %v", id, err)
+	}
+
+	resp, err := c.sendRequest(ctx, "PUT", c.getImportJobEndpoint(id), body, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, newResourceError(resp, "ImportJob", id, "")
+	}
+
+	return readResponse[ImportJob](resp)
+}
+
+// ListImportJobs retrieves all the import jobs on the instance, following each page of results.
+func (c *Client) ListImportJobs(ctx context.Context) ([]ImportJob, error) {
+	return listAll[ImportJob](ctx, c, c.getImportJobEndpoint(), func(resp *http.Response) error {
+		return newResourceError(resp, "ImportJob", "", "")
+	})
+}
+
+// GetImportJob retrieves the import job with the given id, including its current status.
+func (c *Client) GetImportJob(ctx context.Context, id string) (*ImportJob, error) {
+	resp, err := c.sendRequest(ctx, "GET", c.getImportJobEndpoint(id), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newResourceError(resp, "ImportJob", id, "")
+	}
+
+	return readResponse[ImportJob](resp)
+}
+
+// DefaultImportJobPollInterval is how often WaitForImportJob polls when it is not given an interval.
+const DefaultImportJobPollInterval = 10 * time.Second
+
+// WaitForImportJob polls the import job at the given interval until it has finished, returning
+// the finished job. Check the Status of the job to see whether the import succeeded. Polling
+// stops if the context is cancelled. If the interval is not positive then
+// DefaultImportJobPollInterval is used.
+func (c *Client) WaitForImportJob(ctx context.Context, id string, interval time.Duration) (*ImportJob, error) {
+	if interval <= 0 {
+		interval = DefaultImportJobPollInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		job, err := c.GetImportJob(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+
+		if job.Status.IsFinished() {
+			return job, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// CancelImportJob requests that a running import job is cancelled. The returned job normally
+// has a status of ImportJobCancelling until the cancellation completes.
+func (c *Client) CancelImportJob(ctx context.Context, id string) (*ImportJob, error) {
+	resp, err := c.sendRequest(ctx, "POST", c.getImportJobEndpoint(id, "cancel"), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer c.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newResourceError(resp, "ImportJob", id, "")
+	}
+
+	return readResponse[ImportJob](resp)
+}
+
+// DeleteImportJob deletes the import job. Running jobs must be cancelled first.
+func (c *Client) DeleteImportJob(ctx context.Context, id string) error {
+	resp, err := c.sendRequest(ctx, "DELETE", c.getImportJobEndpoint(id), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer c.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return newResourceError(resp, "ImportJob", id, "")
+	}
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package digitaltwin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newImportJobServer creates a test server for the import job APIs which only knows the
+// "import01" job. The job reports as running until it has been retrieved runningPolls times.
+func newImportJobServer(requests *[]*http.Request, bodies *[]string, runningPolls int) *httptest.Server {
+	polls := 0
+	job := func(status string) string {
+		return fmt.Sprintf("{ \"id\": \"import01\", \"inputBlobUri\": \"https://store/input.ndjson\", \"outputBlobUri\": \"https://store/output.log\", \"status\": %q, \"createdDateTime\": \"2023-10-31T09:09:17Z\" }", status)
+	}
+
+	return newRecordingServer(requests, bodies, func(w http.ResponseWriter, req *http.Request, body string) {
+		switch {
+		case req.URL.Path == "/jobs/imports":
+			fmt.Fprintf(w, "{ \"value\": [ %s ] }", job("succeeded"))
+		case req.URL.Path == "/jobs/imports/import01" && req.Method == "PUT":
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, job("notstarted"))
+		case req.URL.Path == "/jobs/imports/import01" && req.Method == "GET":
+			polls++
+			if polls <= runningPolls {
+				fmt.Fprint(w, job("running"))
+			} else {
+				fmt.Fprint(w, job("succeeded"))
+			}
+		case req.URL.Path == "/jobs/imports/import01/cancel" && req.Method == "POST":
+			fmt.Fprint(w, job("cancelling"))
+		case req.URL.Path == "/jobs/imports/import01" && req.Method == "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "{ \"error\": { \"code\": \"ImportJobNotFound\", \"message\": \"There is no import job with the ID\" } }")
+		}
+	})
+}
+
+func TestClient_CreateImportJob(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newImportJobServer(&requests, &bodies, 0)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	job, err := client.CreateImportJob(context.Background(), "import01", "https://store/input.ndjson", "https://store/output.log")
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if job.Status != ImportJobNotStarted || job.CreatedDateTime == nil {
+		t.Errorf("Unexpected import job %v", job)
+	}
+
+	if requests[0].URL.Query().Get("api-version") != importJobsApiVersion {
+		t.Errorf("Expected api version %s, but got %s", importJobsApiVersion, requests[0].URL.Query().Get("api-version"))
+	}
+
+	if bodies[0] != `{"inputBlobUri":"https://store/input.ndjson","outputBlobUri":"https://store/output.log"}` {
+		t.Errorf("Unexpected request body '%s'", bodies[0])
+	}
+}
+
+func TestClient_ListImportJobs(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newImportJobServer(&requests, &bodies, 0)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	jobs, err := client.ListImportJobs(context.Background())
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if len(jobs) != 1 || jobs[0].Id != "import01" || jobs[0].Status != ImportJobSucceeded {
+		t.Errorf("Unexpected import jobs %v", jobs)
+	}
+}
+
+func TestClient_WaitForImportJob(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newImportJobServer(&requests, &bodies, 2)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	job, err := client.WaitForImportJob(context.Background(), "import01", time.Millisecond)
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if job.Status != ImportJobSucceeded {
+		t.Errorf("Expected succeeded status, but got %s", job.Status)
+	}
+
+	if len(requests) != 3 {
+		t.Errorf("Expected 3 polls, but got %d", len(requests))
+	}
+}
+
+func TestClient_WaitForImportJob_ZeroInterval(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newImportJobServer(&requests, &bodies, 0)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	job, err := client.WaitForImportJob(context.Background(), "import01", 0)
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if job.Status != ImportJobSucceeded || len(requests) != 1 {
+		t.Errorf("Expected a single poll of the succeeded job, but got %s after %d polls", job.Status, len(requests))
+	}
+}
+
+func TestClient_WaitForImportJob_Cancelled(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newImportJobServer(&requests, &bodies, 1000)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	_, err := client.WaitForImportJob(ctx, "import01", 5*time.Millisecond)
+	if err == nil {
+		t.Error("Expected an error once the context was cancelled")
+	}
+}
+
+func TestClient_CancelAndDeleteImportJob(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newImportJobServer(&requests, &bodies, 0)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	job, err := client.CancelImportJob(context.Background(), "import01")
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if job.Status != ImportJobCancelling || job.Status.IsFinished() {
+		t.Errorf("Expected cancelling status, but got %s", job.Status)
+	}
+
+	if err = client.DeleteImportJob(context.Background(), "import01"); err != nil {
+		t.Errorf("Expected nil error, but got %v", err)
+	}
+
+	err = client.DeleteImportJob(context.Background(), "import99")
+	if err == nil || !strings.Contains(err.Error(), "ImportJob: import99") {
+		t.Errorf("Expected not found error for import99, but got %v", err)
+	}
+}
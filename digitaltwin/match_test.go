@@ -0,0 +1,42 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/models"
+	"azure-adt-example/digitaltwin/models/rec33"
+	"azure-adt-example/digitaltwin/query"
+	"testing"
+)
+
+func TestExecuteBuilder2_Match(t *testing.T) {
+	var queries []string
+	server := newQueryServer(&queries, "{ \"value\": [ { \"level\": { \"$dtId\": \"Level1\" }, \"child\": { \"$dtId\": \"Level2\" } }, { \"level\": { \"$dtId\": \"Level1\" }, \"child\": { \"$dtId\": \"Level3\" } } ], \"continuationToken\": null }")
+	defer server.Close()
+
+	child := models.WithAlias(rec33.Level{}, "child")
+
+	builder := query.NewBuilder(rec33.Level{}, false, false)
+	err := builder.AddMatch(rec33.Level{}, child, query.MatchRelationship{
+		Names:     []string{"isPartOf"},
+		Direction: query.Incoming,
+		MinHops:   1,
+		MaxHops:   5,
+	}, false, false)
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	_ = builder.WhereId(rec33.Level{}, "Level1")
+
+	results, err := ExecuteBuilder2[rec33.Level, rec33.Level](newTestClient(server), builder)
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if len(results) != 2 || results[0].Twin1.ExternalId != "Level1" || results[0].Twin2.ExternalId != "Level2" || results[1].Twin2.ExternalId != "Level3" {
+		t.Errorf("Unexpected results %+v", results)
+	}
+
+	expected := "SELECT level, child FROM digitaltwins MATCH (level)<-[:isPartOf*1..5]-(child) WHERE level.$dtId = 'Level1'"
+	if len(queries) != 1 || queries[0] != expected {
+		t.Errorf("Expected query %s, but got %v", expected, queries)
+	}
+}
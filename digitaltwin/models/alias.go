@@ -0,0 +1,64 @@
+package models
+
+// AliasedModel is an IModel which is referred to by a different alias in a query, so that the
+// same model type can be part of a query more than once.
+type AliasedModel struct {
+	IModel
+	alias string
+}
+
+// WithAlias returns the model referred to by alias rather than the alias of its type.
+//
+//	buildingA := models.WithAlias(rec33.Building{}, "buildingA")
+//	buildingB := models.WithAlias(rec33.Building{}, "buildingB")
+func WithAlias(model IModel, alias string) AliasedModel {
+	return AliasedModel{IModel: Unwrap(model), alias: alias}
+}
+
+func (m AliasedModel) Alias() string {
+	return m.alias
+}
+
+// Unwrap returns the model which has been given the alias.
+func (m AliasedModel) Unwrap() IModel {
+	return m.IModel
+}
+
+// Unwrap returns the model underneath any alias it has been given, or the model itself if it
+// has not been given one.
+func Unwrap(model IModel) IModel {
+	if aliased, ok := model.(AliasedModel); ok {
+		return aliased.IModel
+	}
+	return model
+}
+
+// AliasedRelationship is a relationship which is bound to an alias when it is joined in a query,
+// so that its properties can be used in where conditions and projections. It is an IModel so it
+// can be used wherever a twin can, but it has no model.
+type AliasedRelationship struct {
+	relationship any
+	alias        string
+}
+
+// RelationshipWithAlias returns the relationship bound to alias. The relationship is either
+// GenericRelationship, or a type which embeds it and adds the relationship properties.
+//
+//	owns := models.RelationshipWithAlias(OwnsRelationship{}, "owns")
+func RelationshipWithAlias(relationship any, alias string) AliasedRelationship {
+	return AliasedRelationship{relationship: relationship, alias: alias}
+}
+
+// Model returns an empty string, as relationships do not have a model.
+func (r AliasedRelationship) Model() string {
+	return ""
+}
+
+func (r AliasedRelationship) Alias() string {
+	return r.alias
+}
+
+// Relationship returns the relationship which has been bound to the alias.
+func (r AliasedRelationship) Relationship() any {
+	return r.relationship
+}
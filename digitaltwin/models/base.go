@@ -1,6 +1,7 @@
 package models
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 	"strings"
@@ -30,3 +31,24 @@ func GetModelAlias[T IModel]() string {
 	typeNameParts := strings.Split(reflect.TypeOf(*new(T)).Name(), ",")
 	return strings.ToLower(typeNameParts[len(typeNameParts)-1])
 }
+
+// NewTwinDocument converts a model into the JSON document expected by the Azure Digital Twin
+// API when creating a twin. The "$metadata" value is replaced with one containing only the
+// model DTMI, and the read-only "$etag" value is removed.
+func NewTwinDocument(model IModel) (map[string]json.RawMessage, error) {
+	content, err := json.Marshal(model)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialise %T: %v", model, err)
+	}
+
+	document := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("%T does not serialise to a JSON object: %v", model, err)
+	}
+
+	metadata, _ := json.Marshal(map[string]string{"$model": model.Model()})
+	document["$metadata"] = metadata
+	delete(document, "$etag")
+
+	return document, nil
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// GenericRelationship defines the properties every Azure Digital Twin relationship has. It can
+// be used directly for relationships without properties, or embedded in a type which adds the
+// relationship properties in the same way as GenericModel is for twins.
+type GenericRelationship struct {
+	RelationshipId string `json:"$relationshipId"`
+	SourceId       string `json:"$sourceId"`
+	TargetId       string `json:"$targetId"`
+	Name           string `json:"$relationshipName"`
+	ETag           string `json:"$etag,omitempty"`
+}
+
+// NewRelationshipDocument converts a relationship into the JSON document expected by the Azure
+// Digital Twin API when creating a relationship. The relationship and source ids are part of
+// the request path, so are removed along with the read-only "$etag" value.
+func NewRelationshipDocument(relationship any) (map[string]json.RawMessage, error) {
+	content, err := json.Marshal(relationship)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialise %T: %v", relationship, err)
+	}
+
+	document := make(map[string]json.RawMessage)
+	if err = json.Unmarshal(content, &document); err != nil {
+		return nil, fmt.Errorf("%T does not serialise to a JSON object: %v", relationship, err)
+	}
+
+	delete(document, "$relationshipId")
+	delete(document, "$sourceId")
+	delete(document, "$etag")
+
+	return document, nil
+}
@@ -0,0 +1,104 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/models"
+	"azure-adt-example/digitaltwin/query"
+	"azure-adt-example/tracing"
+	"context"
+)
+
+// PageOptions controls which page of results is returned by ExecutePage.
+type PageOptions struct {
+	// ContinuationToken is the opaque token returned with a previous Page. If empty then the
+	// first page of results is returned.
+	ContinuationToken string
+
+	// MaxItemsPerPage is the maximum number of rows to return in the page. If zero then the
+	// Client MaxItemsPerPage value is used.
+	MaxItemsPerPage uint
+}
+
+// Page is a single page of typed query results along with the token needed to retrieve the
+// page which follows it.
+type Page[R any] struct {
+	// Items contains the decoded rows of the page.
+	Items []R
+
+	// ContinuationToken is the opaque value which can be passed back in PageOptions to
+	// retrieve the next page. If this is empty then no further results are available.
+	ContinuationToken string
+}
+
+// HasMore returns true if there are further pages of results after this one.
+func (p *Page[R]) HasMore() bool {
+	return len(p.ContinuationToken) != 0
+}
+
+// ExecutePage queries the Azure Digital Twin using the query created from the Builder instance
+// and returns a single page of models.IModel types.
+func ExecutePage[T1 models.IModel](ctx context.Context, client *Client, builder *query.Builder, options *PageOptions) (*Page[T1], error) {
+	decode, err := newRowDecoder[T1](builder)
+	if err != nil {
+		return nil, err
+	}
+
+	return executePage(ctx, client, builder, decode, options)
+}
+
+// ExecutePage2 queries the Azure Digital Twin using the query created from the Builder instance
+// and returns a single page of TwinResult2 objects.
+func ExecutePage2[T1, T2 models.IModel](ctx context.Context, client *Client, builder *query.Builder, options *PageOptions) (*Page[TwinResult2[T1, T2]], error) {
+	decode, err := newRowDecoder2[T1, T2](builder)
+	if err != nil {
+		return nil, err
+	}
+
+	return executePage(ctx, client, builder, decode, options)
+}
+
+// ExecutePage3 queries the Azure Digital Twin using the query created from the Builder instance
+// and returns a single page of TwinResult3 objects.
+func ExecutePage3[T1, T2, T3 models.IModel](ctx context.Context, client *Client, builder *query.Builder, options *PageOptions) (*Page[TwinResult3[T1, T2, T3]], error) {
+	decode, err := newRowDecoder3[T1, T2, T3](builder)
+	if err != nil {
+		return nil, err
+	}
+
+	return executePage(ctx, client, builder, decode, options)
+}
+
+// executePage retrieves the page identified by the options and decodes each of its rows.
+func executePage[R any](ctx context.Context, client *Client, builder *query.Builder, decode rowDecoder[R], options *PageOptions) (page *Page[R], err error) {
+	pager, err := newPager(client, builder, decode)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := client.tracer().Start(ctx, tracing.SpanExecutePage, tracing.Attr(tracing.AttributeQuery, pager.query))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
+	if options != nil {
+		if options.ContinuationToken != "" {
+			pager.continuationToken = &options.ContinuationToken
+		}
+		if options.MaxItemsPerPage != 0 {
+			pager.maxItemsPerPage = options.MaxItemsPerPage
+		}
+	}
+
+	if err := pager.fetchPage(ctx); err != nil {
+		return nil, err
+	}
+
+	items := make([]R, len(pager.page))
+	for i, row := range pager.page {
+		if items[i], err = decode(row); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Page[R]{Items: items, ContinuationToken: pager.ContinuationToken()}, nil
+}
@@ -0,0 +1,99 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/models/rec33"
+	"azure-adt-example/digitaltwin/query"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExecutePage_ResumeFromToken(t *testing.T) {
+	var requested []string
+	server := newPagedServer(3, &requested)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	first, err := ExecutePage[rec33.Building](context.Background(), client, query.NewBuilder(rec33.Building{}, false, false), nil)
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if len(first.Items) != 1 || first.Items[0].ExternalId != "building01" {
+		t.Errorf("Expected first page to contain building01, but got %v", first.Items)
+	}
+
+	if !first.HasMore() || first.ContinuationToken != "page2" {
+		t.Logf("Expected continuation token 'page2', but got '%s'", first.ContinuationToken)
+		t.FailNow()
+	}
+
+	second, err := ExecutePage[rec33.Building](context.Background(), client, query.NewBuilder(rec33.Building{}, false, false), &PageOptions{ContinuationToken: first.ContinuationToken})
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if len(second.Items) != 1 || second.Items[0].ExternalId != "building02" {
+		t.Errorf("Expected second page to contain building02, but got %v", second.Items)
+	}
+
+	// Resuming from a stored token does not need any earlier state
+	last, err := ExecutePage[rec33.Building](context.Background(), client, query.NewBuilder(rec33.Building{}, false, false), &PageOptions{ContinuationToken: second.ContinuationToken})
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if last.HasMore() {
+		t.Errorf("Expected final page to have no continuation token, but got '%s'", last.ContinuationToken)
+	}
+
+	expectedRequests := []string{"", "page2", "page3"}
+	if !reflect.DeepEqual(requested, expectedRequests) {
+		t.Errorf("Expected requests for %v, but got %v", expectedRequests, requested)
+	}
+}
+
+func TestExecutePage2_MaxItemsPerPage(t *testing.T) {
+	var pageSize string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.RequestURI == "/tenant1/oauth2/token" && req.Method == "POST" {
+			authResponse := getValidAuthenticationResponse()
+			fmt.Fprintf(w, authResponse)
+		} else if strings.HasPrefix(req.RequestURI, "/query?api-version") && req.Method == "POST" {
+			pageSize = req.Header.Get("Max-Items-Per-Page")
+			fmt.Fprintf(w, get2EntityResponseBody())
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	builder := query.NewBuilder(rec33.Company{}, false, false)
+	_ = builder.AddJoin(rec33.Company{}, rec33.Building{}, "owns", false, false)
+
+	page, err := ExecutePage2[rec33.Company, rec33.Building](context.Background(), client, builder, &PageOptions{MaxItemsPerPage: 25})
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if pageSize != "25" {
+		t.Errorf("Expected max items per page of 25, but got '%s'", pageSize)
+	}
+
+	if len(page.Items) != 2 || page.Items[1].Twin2.ExternalId != "building02" {
+		t.Errorf("Expected 2 rows ending with building02, but got %v", page.Items)
+	}
+
+	if page.HasMore() {
+		t.Errorf("Expected no continuation token, but got '%s'", page.ContinuationToken)
+	}
+}
@@ -0,0 +1,368 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/models"
+	"azure-adt-example/digitaltwin/query"
+	"azure-adt-example/logging"
+	"azure-adt-example/tracing"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// rowDecoder converts a single row of a query result into the type returned by a Pager.
+type rowDecoder[R any] func(row json.RawMessage) (R, error)
+
+// Pager iterates over the results of a query, retrieving each page from the Azure Digital
+// Twin only when the rows of the previous page have been consumed. Rows are decoded as they
+// are iterated, so stopping early avoids both the decoding and the remaining page requests.
+//
+//	pager, err := digitaltwin.NewPager[rec33.Building](client, builder)
+//	for pager.Next(ctx) {
+//		building := pager.Value()
+//	}
+//	if err := pager.Err(); err != nil {
+//		...
+//	}
+type Pager[R any] struct {
+	client            *Client
+	query             string
+	decode            rowDecoder[R]
+	page              digitalTwinResults
+	index             int
+	continuationToken *string
+	maxItemsPerPage   uint
+	pages             int
+	done              bool
+	current           R
+	err               error
+}
+
+// checkNotCount returns an error if the builder creates a count query, as its single row cannot
+// be decoded into the results. It is checked before any projections are added to the builder.
+func checkNotCount(builder *query.Builder) error {
+	if builder.IsCount() {
+		return fmt.Errorf("the query only returns a count, use Count to retrieve it")
+	}
+	return nil
+}
+
+// newPager generates the query from the builder and creates a Pager which uses the decoder
+// to convert each row. Count queries are rejected.
+func newPager[R any](client *Client, builder *query.Builder, decode rowDecoder[R]) (*Pager[R], error) {
+	if err := checkNotCount(builder); err != nil {
+		return nil, err
+	}
+
+	generatedQuery, err := builder.CreateQuery()
+	if err != nil {
+		return nil, fmt.Errorf("unable to generate digital twin query: %s", err)
+	}
+
+	return &Pager[R]{client: client, query: *generatedQuery, decode: decode, maxItemsPerPage: client.MaxItemsPerPage}, nil
+}
+
+// NewPager creates a Pager which returns a single models.IModel type for each row.
+func NewPager[T1 models.IModel](client *Client, builder *query.Builder) (*Pager[T1], error) {
+	decode, err := newRowDecoder[T1](builder)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPager(client, builder, decode)
+}
+
+// NewPager2 creates a Pager which returns a TwinResult2 for each row.
+func NewPager2[T1, T2 models.IModel](client *Client, builder *query.Builder) (*Pager[TwinResult2[T1, T2]], error) {
+	decode, err := newRowDecoder2[T1, T2](builder)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPager(client, builder, decode)
+}
+
+// NewPager3 creates a Pager which returns a TwinResult3 for each row.
+func NewPager3[T1, T2, T3 models.IModel](client *Client, builder *query.Builder) (*Pager[TwinResult3[T1, T2, T3]], error) {
+	decode, err := newRowDecoder3[T1, T2, T3](builder)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPager(client, builder, decode)
+}
+
+// projectionAlias returns the alias of the twin of type T which is read from each row. The first
+// projection of type T which is not in use is chosen, otherwise the first source of type T which
+// is not in use is added to the projections. This allows the same type to be returned more than
+// once when it has been given different aliases with models.WithAlias.
+func projectionAlias[T models.IModel](builder *query.Builder, used map[string]bool) (string, error) {
+	target := reflect.TypeOf(*new(T))
+	isTarget := func(model models.IModel) bool {
+		return !used[model.Alias()] && reflect.TypeOf(models.Unwrap(model)) == target
+	}
+
+	for _, projection := range builder.Projections() {
+		if isTarget(projection) {
+			used[projection.Alias()] = true
+			return projection.Alias(), nil
+		}
+	}
+
+	for _, source := range builder.Sources() {
+		if isTarget(source) {
+			used[source.Alias()] = true
+			return source.Alias(), builder.AddProjection(source)
+		}
+	}
+
+	model := *new(T)
+	used[model.Alias()] = true
+	return model.Alias(), builder.AddProjection(model)
+}
+
+// newRowDecoder adds the projection for T1 to the builder and creates a decoder which reads it
+// from each row.
+func newRowDecoder[T1 models.IModel](builder *query.Builder) (rowDecoder[T1], error) {
+	if err := checkNotCount(builder); err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+
+	alias1, err := projectionAlias[T1](builder, used)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := newRowColumns(column[T1](alias1))
+
+	return func(row json.RawMessage) (T1, error) {
+		t1 := new(T1)
+		err := columns.decode(row, t1)
+		return *t1, err
+	}, nil
+}
+
+// newRowDecoder2 adds the projections for T1 and T2 to the builder and creates a decoder which
+// reads them from each row into a TwinResult2.
+func newRowDecoder2[T1, T2 models.IModel](builder *query.Builder) (rowDecoder[TwinResult2[T1, T2]], error) {
+	if err := checkNotCount(builder); err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+
+	alias1, err := projectionAlias[T1](builder, used)
+	if err != nil {
+		return nil, err
+	}
+
+	alias2, err := projectionAlias[T2](builder, used)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := newRowColumns(column[T1](alias1), column[T2](alias2))
+
+	return func(row json.RawMessage) (TwinResult2[T1, T2], error) {
+		t1 := new(T1)
+		t2 := new(T2)
+
+		if err := columns.decode(row, t1, t2); err != nil {
+			return TwinResult2[T1, T2]{}, err
+		}
+
+		return NewTwinResult2(t1, t2), nil
+	}, nil
+}
+
+// newRowDecoder3 adds the projections for T1, T2 and T3 to the builder and creates a decoder
+// which reads them from each row into a TwinResult3.
+func newRowDecoder3[T1, T2, T3 models.IModel](builder *query.Builder) (rowDecoder[TwinResult3[T1, T2, T3]], error) {
+	if err := checkNotCount(builder); err != nil {
+		return nil, err
+	}
+
+	used := make(map[string]bool)
+
+	alias1, err := projectionAlias[T1](builder, used)
+	if err != nil {
+		return nil, err
+	}
+
+	alias2, err := projectionAlias[T2](builder, used)
+	if err != nil {
+		return nil, err
+	}
+
+	alias3, err := projectionAlias[T3](builder, used)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := newRowColumns(column[T1](alias1), column[T2](alias2), column[T3](alias3))
+
+	return func(row json.RawMessage) (TwinResult3[T1, T2, T3], error) {
+		t1 := new(T1)
+		t2 := new(T2)
+		t3 := new(T3)
+
+		if err := columns.decode(row, t1, t2, t3); err != nil {
+			return TwinResult3[T1, T2, T3]{}, err
+		}
+
+		return NewTwinResult3(t1, t2, t3), nil
+	}, nil
+}
+
+// Next advances the Pager to the next row, retrieving the next page of results if the current
+// page has been consumed. It returns false when there are no more rows, or when an error has
+// occurred, which is then available from Err.
+func (p *Pager[R]) Next(ctx context.Context) bool {
+	if p.err != nil {
+		return false
+	}
+
+	for p.index >= len(p.page) {
+		if p.done {
+			return false
+		}
+
+		if err := p.fetchPage(ctx); err != nil {
+			p.err = err
+			return false
+		}
+	}
+
+	row := p.page[p.index]
+	p.index++
+
+	value, err := p.decode(row)
+	if err != nil {
+		p.err = err
+		return false
+	}
+
+	p.current = value
+	return true
+}
+
+// Value returns the row the Pager is currently positioned at.
+func (p *Pager[R]) Value() R {
+	return p.current
+}
+
+// Err returns the error which stopped the Pager, if any.
+func (p *Pager[R]) Err() error {
+	return p.err
+}
+
+// ContinuationToken returns the token for the page after the one currently being iterated, or
+// an empty string if there are no further pages. Combined with ExecutePage it allows a query to
+// be resumed from a page boundary.
+func (p *Pager[R]) ContinuationToken() string {
+	if p.done || p.continuationToken == nil {
+		return ""
+	}
+	return *p.continuationToken
+}
+
+// fetchPage retrieves the next page of results, replacing the current page.
+func (p *Pager[R]) fetchPage(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	ctx, span := p.client.tracer().Start(ctx, tracing.SpanFetchPage,
+		tracing.Attr(tracing.AttributeQuery, p.query),
+		tracing.Attr(tracing.AttributePage, p.pages+1),
+		tracing.Attr(tracing.AttributePageSize, p.maxItemsPerPage))
+	defer span.End()
+
+	data, err := p.client.queryTwin(ctx, p.query, p.continuationToken, p.maxItemsPerPage)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	p.page = data.Results
+	p.index = 0
+	p.pages++
+
+	p.client.logger().Log(logging.LevelDebug, "retrieved query page", logging.F(logging.FieldPage, p.pages), logging.F(logging.FieldCount, len(p.page)))
+
+	if data.HasContinuationToken() {
+		p.continuationToken = &data.ContinuationToken
+	} else {
+		p.done = true
+	}
+
+	return nil
+}
+
+// collect iterates over every row of the Pager, returning them as a single slice.
+func collect[R any](ctx context.Context, pager *Pager[R]) ([]R, error) {
+	ctx, span := pager.client.tracer().Start(ctx, tracing.SpanExecuteBuilder, tracing.Attr(tracing.AttributeQuery, pager.query))
+	defer span.End()
+
+	results := make([]R, 0)
+
+	for pager.Next(ctx) {
+		results = append(results, pager.Value())
+	}
+
+	if err := pager.Err(); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetAttributes(tracing.Attr(tracing.AttributePage, pager.pages), tracing.Attr(tracing.AttributeResultCount, len(results)))
+
+	pager.client.logger().Log(logging.LevelDebug, "query completed", logging.F(logging.FieldPage, pager.pages), logging.F(logging.FieldCount, len(results)))
+	return results, nil
+}
+
+// column returns the field of a rowColumns struct which reads the twin of type T from the column
+// with the given alias.
+func column[T any](alias string) reflect.StructField {
+	return reflect.StructField{
+		Type: reflect.TypeOf(new(T)),
+		Tag:  reflect.StructTag(fmt.Sprintf("json:%q", alias)),
+	}
+}
+
+// rowColumns decodes the columns of a row straight into their targets, rather than through an
+// intermediate map of the columns. It uses a struct type with a pointer field for each column,
+// which is set to the target before the row is unmarshalled. Columns missing from the row leave
+// their target unchanged.
+type rowColumns struct {
+	structType reflect.Type
+	types      string
+}
+
+// newRowColumns creates the struct type with the fields, which are named in order.
+func newRowColumns(fields ...reflect.StructField) rowColumns {
+	types := make([]string, len(fields))
+	for i := range fields {
+		fields[i].Name = fmt.Sprintf("Column%d", i+1)
+		types[i] = fields[i].Type.Elem().String()
+	}
+	return rowColumns{structType: reflect.StructOf(fields), types: strings.Join(types, ", ")}
+}
+
+// decode unmarshalls the row into the targets, which are pointers to the types of the fields.
+func (c rowColumns) decode(row json.RawMessage, targets ...any) error {
+	columns := reflect.New(c.structType).Elem()
+	for i, target := range targets {
+		columns.Field(i).Set(reflect.ValueOf(target))
+	}
+
+	if err := json.Unmarshal(row, columns.Addr().Interface()); err != nil {
+		return fmt.Errorf("unable to parse %s into %s: %v", row, c.types, err)
+	}
+
+	return nil
+}
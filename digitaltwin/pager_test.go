@@ -0,0 +1,215 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/azuread"
+	"azure-adt-example/digitaltwin/models/rec33"
+	"azure-adt-example/digitaltwin/query"
+	"azure-adt-example/logging"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newPagedServer creates a test server which returns a single building per page, with each
+// page pointing at the next until pageCount pages have been served.
+func newPagedServer(pageCount int, requested *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.RequestURI == "/tenant1/oauth2/token" && req.Method == "POST" {
+			authResponse := getValidAuthenticationResponse()
+			fmt.Fprintf(w, authResponse)
+		} else if strings.HasPrefix(req.RequestURI, "/query?api-version") && req.Method == "POST" {
+			var body struct {
+				ContinuationToken string `json:"continuationToken"`
+			}
+			_ = json.NewDecoder(req.Body).Decode(&body)
+			*requested = append(*requested, body.ContinuationToken)
+
+			page := 1
+			if body.ContinuationToken != "" {
+				_, _ = fmt.Sscanf(body.ContinuationToken, "page%d", &page)
+			}
+
+			nextToken := ""
+			if page < pageCount {
+				nextToken = fmt.Sprintf("page%d", page+1)
+			}
+
+			fmt.Fprintf(w, "{ \"value\": [ { \"building\": { \"$dtId\": \"building%02d\" } } ], \"continuationToken\": \"%s\" }", page, nextToken)
+		}
+	}))
+}
+
+func newTestClient(server *httptest.Server) *Client {
+	serverUrl, _ := url.Parse(server.URL)
+
+	conf := azuread.TwinConfiguration{
+		URL:          *serverUrl,
+		ClientId:     "client1",
+		ClientSecret: "secret1",
+		TenantId:     "tenant1",
+		ResourceId:   "resource1",
+		AuthorityUrl: *serverUrl,
+	}
+
+	token := azuread.AccessToken{AccessToken: "abc123"}
+	return NewClient(&conf, &token)
+}
+
+func TestPager_AllPages(t *testing.T) {
+	var requested []string
+	server := newPagedServer(3, &requested)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	pager, err := NewPager[rec33.Building](client, query.NewBuilder(rec33.Building{}, false, false))
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	var ids []string
+	for pager.Next(context.Background()) {
+		ids = append(ids, pager.Value().ExternalId)
+	}
+
+	if pager.Err() != nil {
+		t.Errorf("Expected nil error, but got %v", pager.Err())
+	}
+
+	if strings.Join(ids, ",") != "building01,building02,building03" {
+		t.Errorf("Expected buildings 01 to 03, but got %v", ids)
+	}
+
+	if len(requested) != 3 {
+		t.Errorf("Expected 3 page requests, but got %d", len(requested))
+	}
+}
+
+func TestPager_StopEarly(t *testing.T) {
+	var requested []string
+	server := newPagedServer(5, &requested)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	pager, err := NewPager[rec33.Building](client, query.NewBuilder(rec33.Building{}, false, false))
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	for pager.Next(context.Background()) {
+		if pager.Value().ExternalId == "building02" {
+			break
+		}
+	}
+
+	if len(requested) != 2 {
+		t.Errorf("Expected only 2 pages to be requested, but got %d", len(requested))
+	}
+}
+
+func TestPager_DecodeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.RequestURI == "/tenant1/oauth2/token" && req.Method == "POST" {
+			authResponse := getValidAuthenticationResponse()
+			fmt.Fprintf(w, authResponse)
+		} else if strings.HasPrefix(req.RequestURI, "/query?api-version") && req.Method == "POST" {
+			fmt.Fprintf(w, "{ \"value\": [ { \"building\": { \"$dtId\": 12 } } ] }")
+		}
+	}))
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	pager, _ := NewPager[rec33.Building](client, query.NewBuilder(rec33.Building{}, false, false))
+
+	if pager.Next(context.Background()) {
+		t.Error("Expected Next to return false for a row which cannot be decoded")
+	}
+
+	if pager.Err() == nil || !strings.Contains(pager.Err().Error(), "unable to parse") {
+		t.Errorf("Expected a parse error, but got %v", pager.Err())
+	}
+}
+
+func TestRowColumns_Decode(t *testing.T) {
+	columns := newRowColumns(column[rec33.Company]("company"), column[rec33.Building]("building"))
+
+	company := rec33.Company{Name: "Unchanged"}
+	building := rec33.Building{}
+
+	row := json.RawMessage("{ \"building\": { \"$dtId\": \"Building1\", \"name\": \"Building 1\" }, \"level\": { \"$dtId\": \"Level1\" } }")
+	if err := columns.decode(row, &company, &building); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if company.Name != "Unchanged" || building.ExternalId != "Building1" || building.Name != "Building 1" {
+		t.Errorf("Unexpected twins %+v and %+v", company, building)
+	}
+
+	err := columns.decode(json.RawMessage("{ \"building\": { \"$dtId\": 12 } }"), &company, &building)
+	if err == nil || !strings.Contains(err.Error(), "into rec33.Company, rec33.Building") {
+		t.Errorf("Expected a parse error naming the twin types, but got %v", err)
+	}
+}
+
+func TestNewPager2_InvalidProjection(t *testing.T) {
+	client := NewClient(&azuread.TwinConfiguration{}, nil)
+
+	_, err := NewPager2[rec33.Company, rec33.Level](client, query.NewBuilder(rec33.Company{}, false, false))
+
+	expectedError := "source level is not part of the query"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', but got %v", expectedError, err)
+	}
+}
+
+// recordingLogger records the messages and fields of each entry logged.
+type recordingLogger struct {
+	entries []string
+}
+
+func (r *recordingLogger) Log(level logging.Level, message string, fields ...logging.Field) {
+	entry := fmt.Sprintf("%s %s", level, message)
+	for _, field := range fields {
+		if field.Key != logging.FieldDuration && field.Key != logging.FieldURL {
+			entry += fmt.Sprintf(" %s=%v", field.Key, field.Value)
+		}
+	}
+	r.entries = append(r.entries, entry)
+}
+
+func TestClient_Logger(t *testing.T) {
+	var requested []string
+	server := newPagedServer(2, &requested)
+	defer server.Close()
+
+	logger := &recordingLogger{}
+	client := newTestClient(server)
+	client.Logger = logger
+
+	_, err := ExecuteBuilder[rec33.Building](client, query.NewBuilder(rec33.Building{}, false, false))
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	expected := []string{
+		"DEBUG querying digital twins query=SELECT building FROM digitaltwins building",
+		"DEBUG request completed method=POST status=200",
+		"DEBUG retrieved query page page=1 count=1",
+		"DEBUG querying digital twins query=SELECT building FROM digitaltwins building",
+		"DEBUG request completed method=POST status=200",
+		"DEBUG retrieved query page page=2 count=1",
+		"DEBUG query completed page=2 count=2",
+	}
+	if strings.Join(logger.entries, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected log entries\n%s", strings.Join(logger.entries, "\n"))
+	}
+}
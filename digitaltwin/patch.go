@@ -0,0 +1,53 @@
+package digitaltwin
+
+import (
+	"strings"
+)
+
+// PatchOperation is a single JSON Patch (RFC 6902) operation applied to a twin.
+type PatchOperation struct {
+	// Op is the operation to perform, one of "add", "replace" or "remove".
+	Op string `json:"op"`
+
+	// Path is the JSON Pointer to the property being changed (e.g. "/name").
+	Path string `json:"path"`
+
+	// Value is the new value of the property. It is not sent for "remove" operations.
+	Value any `json:"value,omitempty"`
+}
+
+// JsonPatch is an ordered set of operations used to update a twin.
+type JsonPatch []PatchOperation
+
+// NewJsonPatch creates an empty JsonPatch which operations can be added to.
+func NewJsonPatch() JsonPatch {
+	return make(JsonPatch, 0)
+}
+
+// Add appends an "add" operation, which creates the property or replaces it if it exists.
+func (jp JsonPatch) Add(path string, value any) JsonPatch {
+	return append(jp, PatchOperation{Op: "add", Path: path, Value: value})
+}
+
+// Replace appends a "replace" operation, which fails if the property does not exist.
+func (jp JsonPatch) Replace(path string, value any) JsonPatch {
+	return append(jp, PatchOperation{Op: "replace", Path: path, Value: value})
+}
+
+// Remove appends a "remove" operation for the property.
+func (jp JsonPatch) Remove(path string) JsonPatch {
+	return append(jp, PatchOperation{Op: "remove", Path: path})
+}
+
+// PropertyPath creates a JSON Pointer from a set of property names, escaping the characters
+// which have special meaning in a pointer.
+func PropertyPath(properties ...string) string {
+	escaper := strings.NewReplacer("~", "~0", "/", "~1")
+
+	escaped := make([]string, len(properties))
+	for i, p := range properties {
+		escaped[i] = escaper.Replace(p)
+	}
+
+	return "/" + strings.Join(escaped, "/")
+}
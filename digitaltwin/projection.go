@@ -0,0 +1,82 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/query"
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// newProjectionDecoder creates a decoder which unmarshalls each row straight into R. R must be a
+// struct, whose fields are matched to the projected columns by json.Unmarshal, or a map with
+// string keys such as map[string]any.
+func newProjectionDecoder[R any]() (rowDecoder[R], error) {
+	rowType := reflect.TypeOf(new(R)).Elem()
+	if rowType.Kind() == reflect.Pointer {
+		rowType = rowType.Elem()
+	}
+
+	isStruct := rowType.Kind() == reflect.Struct
+	isMap := rowType.Kind() == reflect.Map && rowType.Key().Kind() == reflect.String
+	if !isStruct && !isMap {
+		return nil, fmt.Errorf("projection type %s must be a struct or a map with string keys", rowType)
+	}
+
+	return func(row json.RawMessage) (R, error) {
+		var result R
+		if err := json.Unmarshal(row, &result); err != nil {
+			return result, fmt.Errorf("unable to parse %s into %T: %v", row, result, err)
+		}
+		return result, nil
+	}, nil
+}
+
+// NewProjectionPager creates a Pager which decodes each row into R. The columns of each row are
+// the twins and properties projected by the Builder, see query.Builder.AddPropertyProjection.
+func NewProjectionPager[R any](client *Client, builder *query.Builder) (*Pager[R], error) {
+	decode, err := newProjectionDecoder[R]()
+	if err != nil {
+		return nil, err
+	}
+
+	return newPager(client, builder, decode)
+}
+
+// ExecuteProjection queries the Azure Digital Twin using the query created from the Builder
+// instance. It returns an array of R, where each row is decoded into a struct using its json
+// tags, or into a map[string]any.
+//
+//	_ = builder.AddPropertyProjection(rec33.Building{}, "Name", "buildingName")
+//	_ = builder.AddPropertyProjection(rec33.Level{}, "Number", "")
+//
+//	type row struct {
+//		BuildingName string `json:"buildingName"`
+//		LevelNumber  int32  `json:"levelNumber"`
+//	}
+//	rows, err := digitaltwin.ExecuteProjection[row](client, builder)
+func ExecuteProjection[R any](client *Client, builder *query.Builder) ([]R, error) {
+	return ExecuteProjectionCtx[R](context.Background(), client, builder)
+}
+
+// ExecuteProjectionCtx is the same as ExecuteProjection, but stops retrieving results and returns
+// the context error if the context is cancelled or its deadline is exceeded.
+func ExecuteProjectionCtx[R any](ctx context.Context, client *Client, builder *query.Builder) ([]R, error) {
+	pager, err := NewProjectionPager[R](client, builder)
+	if err != nil {
+		return nil, err
+	}
+
+	return collect(ctx, pager)
+}
+
+// ExecuteProjectionPage queries the Azure Digital Twin using the query created from the Builder
+// instance and returns a single page of R.
+func ExecuteProjectionPage[R any](ctx context.Context, client *Client, builder *query.Builder, options *PageOptions) (*Page[R], error) {
+	decode, err := newProjectionDecoder[R]()
+	if err != nil {
+		return nil, err
+	}
+
+	return executePage(ctx, client, builder, decode, options)
+}
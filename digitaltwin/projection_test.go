@@ -0,0 +1,201 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/models"
+	"azure-adt-example/digitaltwin/models/rec33"
+	"azure-adt-example/digitaltwin/query"
+	"context"
+	"strings"
+	"testing"
+)
+
+// projectionBody is a single page of projected properties.
+const projectionBody = "{ \"value\": [ { \"buildingName\": \"Building 1\", \"levelNumber\": 1 }, { \"buildingName\": \"Building 1\", \"levelNumber\": 2 } ], \"continuationToken\": null }"
+
+func newProjectionBuilder(t *testing.T) *query.Builder {
+	builder := query.NewBuilder(rec33.Building{}, false, false)
+	if err := builder.AddJoin(rec33.Building{}, rec33.Level{}, "isPartOf", false, false); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	if err := builder.AddPropertyProjection(rec33.Building{}, "Name", "buildingName"); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	if err := builder.AddPropertyProjection(rec33.Level{}, "Number", ""); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	return builder
+}
+
+func TestExecuteProjection_Struct(t *testing.T) {
+	var queries []string
+	server := newQueryServer(&queries, projectionBody)
+	defer server.Close()
+
+	type row struct {
+		BuildingName string `json:"buildingName"`
+		LevelNumber  int32  `json:"levelNumber"`
+	}
+
+	rows, err := ExecuteProjection[row](newTestClient(server), newProjectionBuilder(t))
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if len(rows) != 2 || rows[0].BuildingName != "Building 1" || rows[1].LevelNumber != 2 {
+		t.Errorf("Unexpected rows %+v", rows)
+	}
+
+	expected := "SELECT building.name AS buildingName, level.levelNumber FROM digitaltwins building JOIN level RELATED building.isPartOf"
+	if len(queries) != 1 || queries[0] != expected {
+		t.Errorf("Expected query %s, but got %v", expected, queries)
+	}
+}
+
+// LevelColumns is embedded in a projection row to test that its fields are promoted. It is
+// exported as json.Unmarshal can only allocate embedded pointers to exported types.
+type LevelColumns struct {
+	LevelNumber int32 `json:"levelNumber"`
+}
+
+func TestExecuteProjection_Embedded(t *testing.T) {
+	var queries []string
+	server := newQueryServer(&queries, projectionBody)
+	defer server.Close()
+
+	type row struct {
+		LevelColumns
+		Name string `json:"BUILDINGNAME"`
+	}
+
+	rows, err := ExecuteProjection[*row](newTestClient(server), newProjectionBuilder(t))
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if len(rows) != 2 || rows[0].Name != "Building 1" || rows[1].LevelNumber != 2 {
+		t.Errorf("Unexpected rows %+v", rows)
+	}
+}
+
+func TestExecuteProjection_EmbeddedPointer(t *testing.T) {
+	var queries []string
+	server := newQueryServer(&queries, projectionBody)
+	defer server.Close()
+
+	type row struct {
+		*LevelColumns
+		Name string `json:"buildingName"`
+	}
+
+	rows, err := ExecuteProjection[row](newTestClient(server), newProjectionBuilder(t))
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if len(rows) != 2 || rows[0].LevelColumns == nil || rows[0].LevelNumber != 1 || rows[1].LevelColumns == nil || rows[1].LevelNumber != 2 {
+		t.Errorf("Unexpected rows %+v", rows)
+	}
+}
+
+func TestExecuteProjection_NameConflict(t *testing.T) {
+	var queries []string
+	server := newQueryServer(&queries, projectionBody)
+	defer server.Close()
+
+	type buildingName struct {
+		BuildingName string
+	}
+	type buildingNameCopy struct {
+		BuildingName string
+	}
+	type row struct {
+		buildingName
+		buildingNameCopy
+		LevelNumber int32 `json:"levelNumber"`
+	}
+
+	rows, err := ExecuteProjection[row](newTestClient(server), newProjectionBuilder(t))
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if len(rows) != 2 || rows[0].buildingName.BuildingName != "" || rows[0].buildingNameCopy.BuildingName != "" || rows[1].LevelNumber != 2 {
+		t.Errorf("Expected conflicting fields at the same depth to be ignored, but got %+v", rows)
+	}
+}
+
+func TestExecuteProjection_Map(t *testing.T) {
+	var queries []string
+	server := newQueryServer(&queries, projectionBody)
+	defer server.Close()
+
+	rows, err := ExecuteProjection[map[string]any](newTestClient(server), newProjectionBuilder(t))
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if len(rows) != 2 || rows[0]["buildingName"] != "Building 1" || rows[1]["levelNumber"] != float64(2) {
+		t.Errorf("Unexpected rows %v", rows)
+	}
+}
+
+func TestExecuteProjection_InvalidType(t *testing.T) {
+	_, err := ExecuteProjection[string](&Client{}, newProjectionBuilder(t))
+	if err == nil || !strings.Contains(err.Error(), "must be a struct or a map with string keys") {
+		t.Errorf("Expected an invalid projection type error, but got %v", err)
+	}
+}
+
+func TestExecuteProjectionPage(t *testing.T) {
+	var queries []string
+	server := newQueryServer(&queries, projectionBody)
+	defer server.Close()
+
+	page, err := ExecuteProjectionPage[map[string]any](context.Background(), newTestClient(server), newProjectionBuilder(t), nil)
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if len(page.Items) != 2 || page.HasMore() {
+		t.Errorf("Expected a single page of 2 rows, but got %+v", page)
+	}
+}
+
+// ownsRelationship is a relationship with a property, used to test projecting relationships.
+type ownsRelationship struct {
+	models.GenericRelationship
+	Since string `json:"since"`
+}
+
+func TestExecuteProjection_Relationship(t *testing.T) {
+	var queries []string
+	server := newQueryServer(&queries, "{ \"value\": [ { \"building\": { \"$dtId\": \"Building1\" }, \"rel\": { \"$relationshipId\": \"rel1\", \"$sourceId\": \"Company1\", \"$targetId\": \"Building1\", \"$relationshipName\": \"owns\", \"since\": \"2021-06-01\" } } ], \"continuationToken\": null }")
+	defer server.Close()
+
+	owns := models.RelationshipWithAlias(ownsRelationship{}, "rel")
+
+	builder := query.NewBuilder(rec33.Company{}, false, false)
+	_ = builder.AddRelationshipJoin(rec33.Company{}, rec33.Building{}, "owns", owns, false, false)
+	_ = builder.WhereClause(owns, "Since", query.GreaterThan, "2020-01-01")
+	_ = builder.AddProjection(rec33.Building{})
+	_ = builder.AddProjection(owns)
+
+	type row struct {
+		Building     rec33.Building   `json:"building"`
+		Relationship ownsRelationship `json:"rel"`
+	}
+
+	rows, err := ExecuteProjection[row](newTestClient(server), builder)
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if len(rows) != 1 || rows[0].Building.ExternalId != "Building1" || rows[0].Relationship.Since != "2021-06-01" || rows[0].Relationship.SourceId != "Company1" {
+		t.Errorf("Unexpected rows %+v", rows)
+	}
+
+	expected := "SELECT building, rel FROM digitaltwins company JOIN building RELATED company.owns rel WHERE rel.since > '2020-01-01'"
+	if len(queries) != 1 || queries[0] != expected {
+		t.Errorf("Expected query %s, but got %v", expected, queries)
+	}
+}
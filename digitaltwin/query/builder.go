@@ -2,7 +2,9 @@ package query
 
 import (
 	"azure-adt-example/digitaltwin/models"
+	"azure-adt-example/logging"
 	"fmt"
+	"reflect"
 	"strings"
 )
 
@@ -15,16 +17,24 @@ type Builder struct {
 	join          []join
 	where         []IWhere
 	project       []models.IModel
+	properties    []*PropertyProjection
+	top           int
+	count         bool
+	logger        logging.Logger
 }
 
 // join represents a join condition, defining the twin being joined from and to, it's
-// relationship and if the target type requires model verification.
+// relationship, the alias of the relationship if it has one and if the target type requires
+// model verification. Joins which are part of a MATCH pattern have the relationships which are
+// traversed in match rather than relationship.
 type join struct {
-	source        models.IModel
-	target        models.IModel
-	relationship  string
-	validateType  bool
-	validateExact bool
+	source            models.IModel
+	target            models.IModel
+	relationship      string
+	relationshipAlias *models.AliasedRelationship
+	match             *MatchRelationship
+	validateType      bool
+	validateExact     bool
 }
 
 // NewBuilder creates a new Builder type based on a required source twin and sets if that twin
@@ -37,29 +47,117 @@ func NewBuilder(from models.IModel, validateType bool, validateExact bool) *Buil
 		join:          make([]join, 0),
 		where:         make([]IWhere, 0),
 		project:       make([]models.IModel, 0),
+		properties:    make([]*PropertyProjection, 0),
 	}
 }
 
+// SetLogger sets the logger which receives the generated queries. By default nothing is logged.
+func (b *Builder) SetLogger(logger logging.Logger) {
+	b.logger = logger
+}
+
 // AddJoin adds a new join condition to the Builder. Joins can only be specified once.
 func (b *Builder) AddJoin(source models.IModel, target models.IModel, relationship string, validateType bool, validateExact bool) error {
-	exists := false
-	for _, j := range b.join {
-		if target.Alias() == j.target.Alias() {
-			exists = true
-			break
-		}
-	}
-
-	if exists {
-		return fmt.Errorf("a target of alias '%s' already exists", target.Alias())
-	}
-
-	join := join{
+	return b.addJoin(join{
 		source:        source,
 		target:        target,
 		relationship:  relationship,
 		validateType:  validateType,
 		validateExact: validateExact,
+	})
+}
+
+// AddRelationshipJoin adds a new join condition to the Builder in the same way as AddJoin, and
+// binds the relationship to an alias, this is the equivalent of writing "JOIN <target> RELATED
+// <source>.<relationship> <alias>" in the query. The aliased relationship can then be used as
+// the source of where conditions and projections.
+func (b *Builder) AddRelationshipJoin(source models.IModel, target models.IModel, relationship string, relationshipAlias models.AliasedRelationship, validateType bool, validateExact bool) error {
+	if reflect.ValueOf(relationshipAlias.Relationship()).Kind() != reflect.Struct {
+		return fmt.Errorf("relationship %T must be a struct", relationshipAlias.Relationship())
+	}
+
+	if err := b.checkRelationshipAlias(relationshipAlias, target); err != nil {
+		return err
+	}
+
+	return b.addJoin(join{
+		source:            source,
+		target:            target,
+		relationship:      relationship,
+		relationshipAlias: &relationshipAlias,
+		validateType:      validateType,
+		validateExact:     validateExact,
+	})
+}
+
+// AddMatch adds the next twin of a MATCH pattern to the Builder, this is the equivalent of
+// writing "MATCH (<source>)-[<relationship>]->(<target>)" in the query. The pattern starts with
+// the twin the Builder was created with, and the source of each match must be the target of the
+// previous one. A Builder can use either AddMatch or AddJoin, but not both.
+//
+//	// MATCH (building)<-[:isPartOf*..5]-(level)
+//	builder.AddMatch(rec33.Building{}, rec33.Level{}, query.MatchRelationship{
+//		Names:     []string{"isPartOf"},
+//		Direction: query.Incoming,
+//		MaxHops:   5,
+//	}, false, false)
+func (b *Builder) AddMatch(source models.IModel, target models.IModel, relationship MatchRelationship, validateType bool, validateExact bool) error {
+	if err := relationship.validate(); err != nil {
+		return err
+	}
+
+	last := b.from
+	if len(b.join) > 0 {
+		last = b.join[len(b.join)-1].target
+	}
+	if source.Alias() != last.Alias() {
+		return fmt.Errorf("match source %s must be %s, the last twin of the pattern", source.Alias(), last.Alias())
+	}
+
+	if relationship.Relationship != nil {
+		if err := b.checkRelationshipAlias(*relationship.Relationship, target); err != nil {
+			return err
+		}
+	}
+
+	relationship.Names = append([]string(nil), relationship.Names...)
+
+	return b.addJoin(join{
+		source:            source,
+		target:            target,
+		relationshipAlias: relationship.Relationship,
+		match:             &relationship,
+		validateType:      validateType,
+		validateExact:     validateExact,
+	})
+}
+
+// checkRelationshipAlias checks the alias of a relationship is valid, and is not already used by
+// the twins of the query or the target of the join it is part of.
+func (b *Builder) checkRelationshipAlias(relationshipAlias models.AliasedRelationship, target models.IModel) error {
+	if b.sourceExists(relationshipAlias) || relationshipAlias.Alias() == target.Alias() {
+		return fmt.Errorf("a relationship of alias '%s' already exists", relationshipAlias.Alias())
+	}
+
+	if !validAlias.MatchString(relationshipAlias.Alias()) {
+		return fmt.Errorf("'%s' is not a valid alias", relationshipAlias.Alias())
+	}
+
+	return nil
+}
+
+// addJoin checks the target of the join is not already part of the query before adding it.
+func (b *Builder) addJoin(join join) error {
+	if len(b.join) > 0 && (b.join[0].match == nil) != (join.match == nil) {
+		return fmt.Errorf("JOIN and MATCH cannot be used in the same query")
+	}
+
+	if b.sourceExists(join.target) {
+		return fmt.Errorf("a target of alias '%s' already exists", join.target.Alias())
+	}
+
+	if !validAlias.MatchString(join.target.Alias()) {
+		return fmt.Errorf("'%s' is not a valid alias", join.target.Alias())
 	}
 
 	b.join = append(b.join, join)
@@ -175,13 +273,76 @@ func (b *Builder) AddProjection(source models.IModel) error {
 	return nil
 }
 
-// sourceExists checks to see if a source has already been added to the builder.
+// AddPropertyProjection adds a single property of a twin to the output of the query, this is the
+// equivalent of writing "SELECT <model type>.<property> AS <alias>" in the query. The property is
+// the name of the field on the model, and alias names the column it is returned in. If alias is
+// empty then the column is named after the JSON name of the property.
+func (b *Builder) AddPropertyProjection(source models.IModel, property string, alias string) error {
+	if !b.sourceExists(source) {
+		return fmt.Errorf("source %s is not part of the query", source.Alias())
+	}
+
+	projection, err := NewPropertyProjection(source, property, alias)
+	if err != nil {
+		return err
+	}
+
+	if b.columnExists(projection.Name()) {
+		return fmt.Errorf("a projection named '%s' already exists", projection.Name())
+	}
+
+	b.properties = append(b.properties, projection)
+
+	return nil
+}
+
+// SetTop limits the number of results returned by the query, this is the equivalent of writing
+// "SELECT TOP(<n>)" in the query. A value of zero removes the limit.
+func (b *Builder) SetTop(n int) error {
+	if n < 0 {
+		return fmt.Errorf("top must not be negative, but got %d", n)
+	}
+
+	b.top = n
+
+	return nil
+}
+
+// SetCount sets if the query only returns the number of matching results, this is the
+// equivalent of writing "SELECT COUNT()" in the query. The projections are ignored. The rows of
+// such a query are not twins, so the execute functions of the digitaltwin package reject it.
+func (b *Builder) SetCount(count bool) {
+	b.count = count
+}
+
+// IsCount returns true if the query only returns the number of matching results.
+func (b *Builder) IsCount() bool {
+	return b.count
+}
+
+// Projections returns the twins which have been added to the output of the query.
+func (b *Builder) Projections() []models.IModel {
+	return append([]models.IModel(nil), b.project...)
+}
+
+// Sources returns the twins which are part of the query, starting with the twin the query is
+// from followed by each join target.
+func (b *Builder) Sources() []models.IModel {
+	sources := []models.IModel{b.from}
+	for _, j := range b.join {
+		sources = append(sources, j.target)
+	}
+	return sources
+}
+
+// sourceExists checks to see if a source, either a twin or an aliased relationship, has
+// already been added to the builder.
 func (b *Builder) sourceExists(source models.IModel) bool {
 	sourceExists := b.from.Alias() == source.Alias()
 
 	if !sourceExists {
 		for _, j := range b.join {
-			if j.target.Alias() == source.Alias() {
+			if j.target.Alias() == source.Alias() || (j.relationshipAlias != nil && j.relationshipAlias.Alias() == source.Alias()) {
 				sourceExists = true
 				break
 			}
@@ -206,16 +367,63 @@ func (b *Builder) projectionExists(source models.IModel) bool {
 	return exists
 }
 
+// columnExists checks to see if a projection already returns a column with the name.
+func (b *Builder) columnExists(name string) bool {
+	for _, p := range b.project {
+		if p.Alias() == name {
+			return true
+		}
+	}
+
+	for _, p := range b.properties {
+		if p.Name() == name {
+			return true
+		}
+	}
+
+	return false
+}
+
 // CreateQuery takes the properties assigned to the Builder and generates a valid
 // Azure Digital Twin SQL query.
 func (b *Builder) CreateQuery() (*string, error) {
-	selectTwins := make([]string, len(b.project))
-	if len(b.project) == 0 {
-		selectTwins = append(selectTwins, b.from.Alias())
-	} else {
-		for i, p := range b.project {
-			selectTwins[i] = p.Alias()
+	if b.count {
+		if b.top != 0 {
+			return nil, fmt.Errorf("TOP and COUNT cannot be used in the same query")
 		}
+		return b.createQuery("SELECT COUNT()")
+	}
+
+	selectTwins := make([]string, 0, len(b.project)+len(b.properties))
+	for _, p := range b.project {
+		selectTwins = append(selectTwins, p.Alias())
+	}
+	for _, p := range b.properties {
+		selectTwins = append(selectTwins, p.GenerateClause())
+	}
+	if len(selectTwins) == 0 {
+		selectTwins = append(selectTwins, b.from.Alias())
+	}
+
+	finalSelect := fmt.Sprintf("SELECT %s", strings.Join(selectTwins, ", "))
+	if b.top != 0 {
+		finalSelect = fmt.Sprintf("SELECT TOP(%d) %s", b.top, strings.Join(selectTwins, ", "))
+	}
+
+	return b.createQuery(finalSelect)
+}
+
+// CreateCountQuery generates a query which returns the number of results the query created by
+// CreateQuery would return, ignoring the projections and any TOP limit.
+func (b *Builder) CreateCountQuery() (*string, error) {
+	return b.createQuery("SELECT COUNT()")
+}
+
+// createQuery generates the query from the select statement and the sources and conditions of
+// the Builder.
+func (b *Builder) createQuery(finalSelect string) (*string, error) {
+	if !validAlias.MatchString(b.from.Alias()) {
+		return nil, fmt.Errorf("'%s' is not a valid alias", b.from.Alias())
 	}
 
 	whereStatements := make([]string, len(b.where))
@@ -231,7 +439,14 @@ func (b *Builder) CreateQuery() (*string, error) {
 
 	joinStatements := make([]string, len(b.join))
 	for i, j := range b.join {
-		joinStatements[i] = fmt.Sprintf("JOIN %s RELATED %s.%s", j.target.Alias(), j.source.Alias(), j.relationship)
+		if j.match != nil {
+			joinStatements[i] = fmt.Sprintf("%s(%s)", j.match.generatePattern(), j.target.Alias())
+		} else {
+			joinStatements[i] = fmt.Sprintf("JOIN %s RELATED %s.%s", j.target.Alias(), j.source.Alias(), j.relationship)
+			if j.relationshipAlias != nil {
+				joinStatements[i] = fmt.Sprintf("%s %s", joinStatements[i], j.relationshipAlias.Alias())
+			}
+		}
 		if j.validateType {
 			validationClause := ModelValidationClause(j.target, j.validateExact)
 			whereStatements = append(whereStatements, validationClause.GenerateClause())
@@ -239,13 +454,16 @@ func (b *Builder) CreateQuery() (*string, error) {
 	}
 
 	joinStatement := strings.Join(joinStatements, " ")
+	if len(b.join) > 0 && b.join[0].match != nil {
+		fromStatement = "digitaltwins"
+		joinStatement = fmt.Sprintf("MATCH (%s)%s", b.from.Alias(), strings.Join(joinStatements, ""))
+	}
 
 	var whereStatement string
 	if len(whereStatements) > 0 {
 		whereStatement = fmt.Sprintf("WHERE %s", strings.Join(whereStatements, " AND "))
 	}
 
-	finalSelect := fmt.Sprintf("SELECT %s", strings.Join(selectTwins, ", "))
 	var finalFrom string
 	if joinStatement == "" {
 		finalFrom = fmt.Sprintf("FROM %s", fromStatement)
@@ -254,6 +472,7 @@ func (b *Builder) CreateQuery() (*string, error) {
 	}
 
 	generatedStatement := strings.TrimSpace(strings.Join([]string{finalSelect, finalFrom, whereStatement}, " "))
+	logging.OrDiscard(b.logger).Log(logging.LevelDebug, "generated query", logging.F(logging.FieldQuery, generatedStatement))
 
 	return &generatedStatement, nil
 }
@@ -3,6 +3,8 @@ package query
 import (
 	"azure-adt-example/digitaltwin/models"
 	"azure-adt-example/digitaltwin/models/rec33"
+	"azure-adt-example/logging"
+	"fmt"
 	"reflect"
 	"strings"
 	"testing"
@@ -319,3 +321,306 @@ func assertExpectedError(t *testing.T, actual error, expected *string) {
 		}
 	}
 }
+
+func TestBuilder_SetLogger(t *testing.T) {
+	logger := &recordingLogger{}
+	builder := NewBuilder(rec33.Building{}, false, false)
+	builder.SetLogger(logger)
+
+	generated, err := builder.CreateQuery()
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if len(logger.queries) != 1 || logger.queries[0] != *generated {
+		t.Errorf("Expected the generated query to be logged, but got %v", logger.queries)
+	}
+}
+
+// recordingLogger records the query field of each entry logged.
+type recordingLogger struct {
+	queries []string
+}
+
+func (r *recordingLogger) Log(_ logging.Level, _ string, fields ...logging.Field) {
+	for _, field := range fields {
+		if field.Key == logging.FieldQuery {
+			r.queries = append(r.queries, fmt.Sprint(field.Value))
+		}
+	}
+}
+
+func TestBuilder_AddPropertyProjection(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   models.IModel
+		property string
+		alias    string
+		expected *string
+	}{
+		{"ValidProjection", rec33.Building{}, "Name", "buildingName", nil},
+		{"NoAlias", rec33.Building{}, "Name", "", nil},
+		{"EmbeddedProperty", rec33.Building{}, "ExternalId", "buildingId", nil},
+		{"InvalidSource", rec33.Level{}, "Name", "", createErrorString("source level is not part of the query")},
+		{"InvalidProperty", rec33.Building{}, "Height", "", createErrorString("field Height does not exist on model rec33.Building")},
+		{"InvalidAlias", rec33.Building{}, "Name", "building name", createErrorString("'building name' is not a valid projection alias")},
+		{"AliasOfTwin", rec33.Building{}, "Name", "company", createErrorString("a projection named 'company' already exists")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builder := NewBuilder(rec33.Company{}, false, false)
+			_ = builder.AddJoin(rec33.Company{}, rec33.Building{}, "owns", false, false)
+			_ = builder.AddProjection(rec33.Company{})
+			err := builder.AddPropertyProjection(test.source, test.property, test.alias)
+
+			assertExpectedError(t, err, test.expected)
+		})
+	}
+}
+
+func TestBuilder_AddPropertyProjection_DuplicateName(t *testing.T) {
+	builder := NewBuilder(rec33.Company{}, false, false)
+	_ = builder.AddJoin(rec33.Company{}, rec33.Building{}, "owns", false, false)
+	_ = builder.AddPropertyProjection(rec33.Company{}, "Name", "")
+	err := builder.AddPropertyProjection(rec33.Building{}, "Name", "")
+
+	assertExpectedError(t, err, createErrorString("a projection named 'name' already exists"))
+
+	if err = builder.AddPropertyProjection(rec33.Building{}, "Name", "buildingName"); err != nil {
+		t.Errorf("Expected an aliased projection to be added, but got error %v", err)
+	}
+}
+
+func TestBuilder_CreateQuery_PropertyProjection(t *testing.T) {
+	builder := NewBuilder(rec33.Building{}, false, false)
+	_ = builder.AddJoin(rec33.Building{}, rec33.Level{}, "isPartOf", false, false)
+	_ = builder.AddProjection(rec33.Building{})
+	_ = builder.AddPropertyProjection(rec33.Building{}, "Name", "buildingName")
+	_ = builder.AddPropertyProjection(rec33.Level{}, "Number", "")
+
+	expected := "SELECT building, building.name AS buildingName, level.levelNumber FROM digitaltwins building JOIN level RELATED building.isPartOf"
+
+	actual, err := builder.CreateQuery()
+
+	if err != nil {
+		t.Errorf("Expected nil error, but got %v", err)
+	} else if *actual != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, *actual)
+	}
+}
+
+func TestBuilder_CreateQuery_Top(t *testing.T) {
+	builder := NewBuilder(rec33.Building{}, false, false)
+	_ = builder.AddPropertyProjection(rec33.Building{}, "Name", "")
+	if err := builder.SetTop(5); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	expected := "SELECT TOP(5) building.name FROM digitaltwins building"
+
+	actual, err := builder.CreateQuery()
+
+	if err != nil {
+		t.Errorf("Expected nil error, but got %v", err)
+	} else if *actual != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, *actual)
+	}
+}
+
+func TestBuilder_SetTop_Negative(t *testing.T) {
+	builder := NewBuilder(rec33.Building{}, false, false)
+	err := builder.SetTop(-1)
+
+	assertExpectedError(t, err, createErrorString("top must not be negative, but got -1"))
+}
+
+func TestBuilder_CreateQuery_Count(t *testing.T) {
+	builder := NewBuilder(rec33.Building{}, false, false)
+	_ = builder.AddJoin(rec33.Building{}, rec33.Level{}, "isPartOf", true, false)
+	_ = builder.WhereId(rec33.Building{}, "Building1")
+	_ = builder.AddProjection(rec33.Level{})
+	builder.SetCount(true)
+
+	expected := "SELECT COUNT() FROM digitaltwins building JOIN level RELATED building.isPartOf WHERE building.$dtId = 'Building1' AND IS_OF_MODEL(level, 'dtmi:digitaltwins:rec_3_3:core:Level;1')"
+
+	actual, err := builder.CreateQuery()
+
+	if err != nil {
+		t.Errorf("Expected nil error, but got %v", err)
+	} else if *actual != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, *actual)
+	}
+}
+
+func TestBuilder_CreateQuery_CountAndTop(t *testing.T) {
+	builder := NewBuilder(rec33.Building{}, false, false)
+	_ = builder.SetTop(5)
+	builder.SetCount(true)
+
+	_, err := builder.CreateQuery()
+
+	assertExpectedError(t, err, createErrorString("TOP and COUNT cannot be used in the same query"))
+}
+
+func TestBuilder_CreateCountQuery(t *testing.T) {
+	builder := NewBuilder(rec33.Building{}, false, false)
+	_ = builder.AddProjection(rec33.Building{})
+	_ = builder.SetTop(5)
+
+	expected := "SELECT COUNT() FROM digitaltwins building"
+
+	actual, err := builder.CreateCountQuery()
+
+	if err != nil {
+		t.Errorf("Expected nil error, but got %v", err)
+	} else if *actual != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, *actual)
+	}
+}
+
+func TestBuilder_AddJoin_Alias(t *testing.T) {
+	buildingA := models.WithAlias(rec33.Building{}, "buildingA")
+	buildingB := models.WithAlias(rec33.Building{}, "buildingB")
+
+	builder := NewBuilder(rec33.Company{}, false, false)
+	if err := builder.AddJoin(rec33.Company{}, buildingA, "owns", false, false); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	if err := builder.AddJoin(rec33.Company{}, buildingB, "owns", true, false); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	_ = builder.WhereId(buildingA, "BuildingA")
+	_ = builder.WhereId(buildingB, "BuildingB")
+	_ = builder.AddProjection(buildingA)
+	_ = builder.AddPropertyProjection(buildingB, "Name", "")
+
+	expected := "SELECT buildingA, buildingB.name FROM digitaltwins company JOIN buildingA RELATED company.owns JOIN buildingB RELATED company.owns WHERE buildingA.$dtId = 'BuildingA' AND buildingB.$dtId = 'BuildingB' AND IS_OF_MODEL(buildingB, 'dtmi:digitaltwins:rec_3_3:core:Building;1')"
+
+	actual, err := builder.CreateQuery()
+
+	if err != nil {
+		t.Errorf("Expected nil error, but got %v", err)
+	} else if *actual != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, *actual)
+	}
+}
+
+func TestBuilder_AddJoin_InvalidAlias(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   models.IModel
+		expected *string
+	}{
+		{"SameAsFrom", models.WithAlias(rec33.Building{}, "company"), createErrorString("a target of alias 'company' already exists")},
+		{"NotIdentifier", models.WithAlias(rec33.Building{}, "building a"), createErrorString("'building a' is not a valid alias")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builder := NewBuilder(rec33.Company{}, false, false)
+			err := builder.AddJoin(rec33.Company{}, test.target, "owns", false, false)
+
+			assertExpectedError(t, err, test.expected)
+		})
+	}
+}
+
+func TestBuilder_CreateQuery_AliasedFrom(t *testing.T) {
+	parent := models.WithAlias(rec33.Level{}, "parent")
+	child := models.WithAlias(rec33.Level{}, "child")
+
+	builder := NewBuilder(parent, false, false)
+	_ = builder.AddJoin(parent, child, "isPartOf", false, false)
+	_ = builder.WhereClause(child, "Number", Equals, 2)
+
+	expected := "SELECT parent FROM digitaltwins parent JOIN child RELATED parent.isPartOf WHERE child.levelNumber = 2"
+
+	actual, err := builder.CreateQuery()
+
+	if err != nil {
+		t.Errorf("Expected nil error, but got %v", err)
+	} else if *actual != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, *actual)
+	}
+
+	if err = builder.WhereId(rec33.Level{}, "Level1"); err == nil {
+		t.Error("Expected an error as level is not an alias in the query")
+	}
+}
+
+func TestBuilder_CreateQuery_InvalidFromAlias(t *testing.T) {
+	builder := NewBuilder(models.WithAlias(rec33.Level{}, "1level"), false, false)
+
+	_, err := builder.CreateQuery()
+
+	assertExpectedError(t, err, createErrorString("'1level' is not a valid alias"))
+}
+
+// ownsRelationship is a relationship with a property, used to test relationship joins.
+type ownsRelationship struct {
+	models.GenericRelationship
+	Since string `json:"since"`
+}
+
+func TestBuilder_AddRelationshipJoin(t *testing.T) {
+	owns := models.RelationshipWithAlias(ownsRelationship{}, "rel")
+
+	builder := NewBuilder(rec33.Company{}, false, false)
+	if err := builder.AddRelationshipJoin(rec33.Company{}, rec33.Building{}, "owns", owns, false, false); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	if err := builder.WhereClause(owns, "Since", GreaterThan, "2020-01-01"); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	if err := builder.WhereClause(owns, "Name", Equals, "owns"); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	if err := builder.WhereStringFunction(owns, "TargetId", StartsWith, "Office"); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	_ = builder.AddProjection(rec33.Building{})
+	_ = builder.AddProjection(owns)
+	_ = builder.AddPropertyProjection(owns, "Since", "ownedSince")
+
+	expected := "SELECT building, rel, rel.since AS ownedSince FROM digitaltwins company JOIN building RELATED company.owns rel WHERE rel.since > '2020-01-01' AND rel.$relationshipName = 'owns' AND STARTSWITH(rel.$targetId, 'Office')"
+
+	actual, err := builder.CreateQuery()
+
+	if err != nil {
+		t.Errorf("Expected nil error, but got %v", err)
+	} else if *actual != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, *actual)
+	}
+}
+
+func TestBuilder_AddRelationshipJoin_Invalid(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       models.IModel
+		relationship models.AliasedRelationship
+		expected     *string
+	}{
+		{"SameAsSource", rec33.Building{}, models.RelationshipWithAlias(ownsRelationship{}, "company"), createErrorString("a relationship of alias 'company' already exists")},
+		{"SameAsTarget", rec33.Building{}, models.RelationshipWithAlias(ownsRelationship{}, "building"), createErrorString("a relationship of alias 'building' already exists")},
+		{"InvalidAlias", rec33.Building{}, models.RelationshipWithAlias(ownsRelationship{}, "owns rel"), createErrorString("'owns rel' is not a valid alias")},
+		{"NotStruct", rec33.Building{}, models.RelationshipWithAlias("owns", "rel"), createErrorString("relationship string must be a struct")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			builder := NewBuilder(rec33.Company{}, false, false)
+			err := builder.AddRelationshipJoin(rec33.Company{}, test.target, "owns", test.relationship, false, false)
+
+			assertExpectedError(t, err, test.expected)
+		})
+	}
+}
+
+func TestBuilder_AddJoin_RelationshipAliasExists(t *testing.T) {
+	builder := NewBuilder(rec33.Company{}, false, false)
+	_ = builder.AddRelationshipJoin(rec33.Company{}, rec33.Building{}, "owns", models.RelationshipWithAlias(models.GenericRelationship{}, "level"), false, false)
+	err := builder.AddJoin(rec33.Building{}, rec33.Level{}, "isPartOf", false, false)
+
+	assertExpectedError(t, err, createErrorString("a target of alias 'level' already exists"))
+}
@@ -0,0 +1,115 @@
+package query
+
+import (
+	"azure-adt-example/digitaltwin/models"
+	"fmt"
+	"strings"
+)
+
+type Direction int
+
+const (
+	Outgoing Direction = iota + 1
+	Incoming
+	Undirected
+)
+
+func (d Direction) String() string {
+	directions := []string{"Outgoing", "Incoming", "Undirected"}
+	if !d.IsValid() {
+		return fmt.Sprintf("Direction(%d)", d)
+	}
+	return directions[d-1]
+}
+
+func (d Direction) IsValid() bool {
+	switch d {
+	case Outgoing, Incoming, Undirected:
+		return true
+	}
+	return false
+}
+
+// MatchRelationship defines the relationships which are traversed between two twins of a MATCH
+// pattern, such as "-[:isPartOf*1..5]->".
+type MatchRelationship struct {
+	// Names of the relationships which can be traversed. If empty then any relationship can be.
+	Names []string
+
+	// Direction the relationships are traversed in. If not set then Outgoing is used.
+	Direction Direction
+
+	// MinHops and MaxHops are the range of the number of relationships traversed. If both are
+	// zero then a single relationship is traversed. If only MaxHops is set then between 1 and
+	// MaxHops relationships are traversed.
+	MinHops int
+	MaxHops int
+
+	// Relationship binds the relationship to an alias so that it can be used in where conditions
+	// and projections. It cannot be used with a variable number of hops.
+	Relationship *models.AliasedRelationship
+}
+
+// validate checks the relationship can be written as part of a MATCH pattern.
+func (m *MatchRelationship) validate() error {
+	if m.Direction != 0 && !m.Direction.IsValid() {
+		return fmt.Errorf("direction specified is not valid")
+	}
+
+	for _, name := range m.Names {
+		if !validAlias.MatchString(name) {
+			return fmt.Errorf("'%s' is not a valid relationship name", name)
+		}
+	}
+
+	if m.MinHops < 0 || m.MaxHops < 0 {
+		return fmt.Errorf("hops must not be negative")
+	}
+
+	if m.MaxHops == 0 && m.MinHops != 0 {
+		return fmt.Errorf("MaxHops must be set when MinHops is set")
+	}
+
+	if m.MinHops > m.MaxHops {
+		return fmt.Errorf("MinHops %d must not be greater than MaxHops %d", m.MinHops, m.MaxHops)
+	}
+
+	if m.Relationship != nil && m.MaxHops != 0 {
+		return fmt.Errorf("a relationship alias cannot be used with a variable number of hops")
+	}
+
+	return nil
+}
+
+// generatePattern returns the relationship as it appears between two twins of a MATCH pattern.
+func (m *MatchRelationship) generatePattern() string {
+	var pattern strings.Builder
+
+	if m.Relationship != nil {
+		pattern.WriteString(m.Relationship.Alias())
+	}
+
+	if len(m.Names) > 0 {
+		pattern.WriteString(":")
+		pattern.WriteString(strings.Join(m.Names, "|"))
+	}
+
+	switch {
+	case m.MaxHops == 0:
+	case m.MinHops == m.MaxHops:
+		fmt.Fprintf(&pattern, "*%d", m.MaxHops)
+	case m.MinHops == 0:
+		fmt.Fprintf(&pattern, "*..%d", m.MaxHops)
+	default:
+		fmt.Fprintf(&pattern, "*%d..%d", m.MinHops, m.MaxHops)
+	}
+
+	switch m.Direction {
+	case Incoming:
+		return fmt.Sprintf("<-[%s]-", pattern.String())
+	case Undirected:
+		return fmt.Sprintf("-[%s]-", pattern.String())
+	default:
+		return fmt.Sprintf("-[%s]->", pattern.String())
+	}
+}
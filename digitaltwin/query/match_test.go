@@ -0,0 +1,172 @@
+package query
+
+import (
+	"azure-adt-example/digitaltwin/models"
+	"azure-adt-example/digitaltwin/models/rec33"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestMatchRelationship_GeneratePattern(t *testing.T) {
+	owns := models.RelationshipWithAlias(models.GenericRelationship{}, "rel")
+
+	tests := []struct {
+		name         string
+		relationship MatchRelationship
+		expected     string
+	}{
+		{"AnyRelationship", MatchRelationship{}, "-[]->"},
+		{"Named", MatchRelationship{Names: []string{"isPartOf"}}, "-[:isPartOf]->"},
+		{"MultipleNames", MatchRelationship{Names: []string{"isPartOf", "contains"}, Direction: Undirected}, "-[:isPartOf|contains]-"},
+		{"Incoming", MatchRelationship{Names: []string{"isPartOf"}, Direction: Incoming}, "<-[:isPartOf]-"},
+		{"ExactHops", MatchRelationship{Names: []string{"isPartOf"}, MinHops: 3, MaxHops: 3}, "-[:isPartOf*3]->"},
+		{"MaxHops", MatchRelationship{Names: []string{"isPartOf"}, MaxHops: 5}, "-[:isPartOf*..5]->"},
+		{"HopRange", MatchRelationship{Names: []string{"isPartOf"}, MinHops: 1, MaxHops: 5}, "-[:isPartOf*1..5]->"},
+		{"Alias", MatchRelationship{Names: []string{"owns"}, Relationship: &owns}, "-[rel:owns]->"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.relationship.validate(); err != nil {
+				t.Fatalf("Expected nil error, but got %v", err)
+			}
+
+			if actual := test.relationship.generatePattern(); actual != test.expected {
+				t.Errorf("Expected %s, but got %s", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestMatchRelationship_Validate(t *testing.T) {
+	owns := models.RelationshipWithAlias(models.GenericRelationship{}, "rel")
+
+	tests := []struct {
+		name         string
+		relationship MatchRelationship
+		expected     *string
+	}{
+		{"InvalidDirection", MatchRelationship{Direction: 99}, createErrorString("direction specified is not valid")},
+		{"InvalidName", MatchRelationship{Names: []string{"is part of"}}, createErrorString("'is part of' is not a valid relationship name")},
+		{"NegativeHops", MatchRelationship{MaxHops: -1}, createErrorString("hops must not be negative")},
+		{"MinHopsOnly", MatchRelationship{MinHops: 2}, createErrorString("MaxHops must be set when MinHops is set")},
+		{"MinGreaterThanMax", MatchRelationship{MinHops: 4, MaxHops: 2}, createErrorString("MinHops 4 must not be greater than MaxHops 2")},
+		{"AliasWithHops", MatchRelationship{MaxHops: 2, Relationship: &owns}, createErrorString("a relationship alias cannot be used with a variable number of hops")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assertExpectedError(t, test.relationship.validate(), test.expected)
+		})
+	}
+}
+
+func TestBuilder_AddMatch(t *testing.T) {
+	builder := NewBuilder(rec33.Company{}, false, false)
+	if err := builder.AddMatch(rec33.Company{}, rec33.Building{}, MatchRelationship{Names: []string{"owns"}}, false, false); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	if err := builder.AddMatch(rec33.Building{}, rec33.Level{}, MatchRelationship{Names: []string{"isPartOf"}, Direction: Incoming, MinHops: 1, MaxHops: 5}, true, false); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	_ = builder.WhereId(rec33.Company{}, "Comp1")
+	_ = builder.WhereClause(rec33.Level{}, "Number", GreaterThan, 2)
+	_ = builder.AddProjection(rec33.Building{})
+	_ = builder.AddProjection(rec33.Level{})
+
+	expected := "SELECT building, level FROM digitaltwins MATCH (company)-[:owns]->(building)<-[:isPartOf*1..5]-(level) WHERE company.$dtId = 'Comp1' AND level.levelNumber > 2 AND IS_OF_MODEL(level, 'dtmi:digitaltwins:rec_3_3:core:Level;1')"
+
+	actual, err := builder.CreateQuery()
+
+	if err != nil {
+		t.Errorf("Expected nil error, but got %v", err)
+	} else if *actual != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, *actual)
+	}
+}
+
+func TestBuilder_AddMatch_RelationshipAlias(t *testing.T) {
+	owns := models.RelationshipWithAlias(models.GenericRelationship{}, "rel")
+
+	builder := NewBuilder(rec33.Company{}, false, false)
+	if err := builder.AddMatch(rec33.Company{}, rec33.Building{}, MatchRelationship{Names: []string{"owns"}, Relationship: &owns}, false, false); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	if err := builder.WhereClause(owns, "TargetId", Equals, "Building1"); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	expected := "SELECT company FROM digitaltwins MATCH (company)-[rel:owns]->(building) WHERE rel.$targetId = 'Building1'"
+
+	actual, err := builder.CreateQuery()
+
+	if err != nil {
+		t.Errorf("Expected nil error, but got %v", err)
+	} else if *actual != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, *actual)
+	}
+}
+
+func TestBuilder_AddMatch_Invalid(t *testing.T) {
+	t.Run("NotLastTwin", func(t *testing.T) {
+		builder := NewBuilder(rec33.Company{}, false, false)
+		_ = builder.AddMatch(rec33.Company{}, rec33.Building{}, MatchRelationship{}, false, false)
+		err := builder.AddMatch(rec33.Company{}, rec33.Level{}, MatchRelationship{}, false, false)
+
+		assertExpectedError(t, err, createErrorString("match source company must be building, the last twin of the pattern"))
+	})
+
+	t.Run("AfterJoin", func(t *testing.T) {
+		builder := NewBuilder(rec33.Company{}, false, false)
+		_ = builder.AddJoin(rec33.Company{}, rec33.Building{}, "owns", false, false)
+		err := builder.AddMatch(rec33.Building{}, rec33.Level{}, MatchRelationship{}, false, false)
+
+		assertExpectedError(t, err, createErrorString("JOIN and MATCH cannot be used in the same query"))
+	})
+
+	t.Run("BeforeJoin", func(t *testing.T) {
+		builder := NewBuilder(rec33.Company{}, false, false)
+		_ = builder.AddMatch(rec33.Company{}, rec33.Building{}, MatchRelationship{}, false, false)
+		err := builder.AddJoin(rec33.Building{}, rec33.Level{}, "isPartOf", false, false)
+
+		assertExpectedError(t, err, createErrorString("JOIN and MATCH cannot be used in the same query"))
+	})
+
+	t.Run("TargetExists", func(t *testing.T) {
+		builder := NewBuilder(rec33.Company{}, false, false)
+		err := builder.AddMatch(rec33.Company{}, rec33.Company{}, MatchRelationship{}, false, false)
+
+		assertExpectedError(t, err, createErrorString("a target of alias 'company' already exists"))
+	})
+}
+
+func TestBuilder_AddMatch_SameModel(t *testing.T) {
+	child := models.WithAlias(rec33.Level{}, "child")
+
+	builder := NewBuilder(rec33.Level{}, false, false)
+	_ = builder.AddMatch(rec33.Level{}, child, MatchRelationship{Names: []string{"isPartOf"}, Direction: Incoming, MaxHops: 5}, false, false)
+	_ = builder.WhereId(rec33.Level{}, "Level1")
+	builder.SetCount(true)
+
+	expected := "SELECT COUNT() FROM digitaltwins MATCH (level)<-[:isPartOf*..5]-(child) WHERE level.$dtId = 'Level1'"
+
+	actual, err := builder.CreateQuery()
+
+	if err != nil {
+		t.Errorf("Expected nil error, but got %v", err)
+	} else if *actual != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, *actual)
+	}
+}
+
+func TestDirection_String(t *testing.T) {
+	if Incoming.String() != "Incoming" {
+		t.Errorf("Expected Incoming, but got %s", Incoming)
+	}
+
+	formatted := fmt.Sprintf("%v", MatchRelationship{Names: []string{"isPartOf"}})
+	if !strings.Contains(formatted, "Direction(0)") {
+		t.Errorf("Expected the unset direction to be formatted, but got %s", formatted)
+	}
+}
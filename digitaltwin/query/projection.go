@@ -0,0 +1,59 @@
+package query
+
+import (
+	"azure-adt-example/digitaltwin/models"
+	"fmt"
+	"regexp"
+)
+
+// validAlias matches the aliases which can be given to twins and projected properties.
+var validAlias = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// PropertyProjection selects a single property of a twin in the query, this is the equivalent
+// of writing "SELECT <alias>.<property> AS <name>" in the query.
+type PropertyProjection struct {
+	source           models.IModel
+	property         string
+	propertyJsonName string
+	alias            string
+}
+
+// NewPropertyProjection creates a PropertyProjection for the property of the source. If alias
+// is empty then the column is named after the JSON name of the property.
+func NewPropertyProjection(source models.IModel, property string, alias string) (*PropertyProjection, error) {
+	jsonPropertyName, err := getPropertyJsonName(source, property)
+	if err != nil {
+		return nil, err
+	}
+
+	if alias != "" && !validAlias.MatchString(alias) {
+		return nil, fmt.Errorf("'%s' is not a valid projection alias", alias)
+	}
+
+	return &PropertyProjection{
+		source:           source,
+		property:         property,
+		propertyJsonName: jsonPropertyName,
+		alias:            alias,
+	}, nil
+}
+
+// Name returns the name of the column which the property is returned in.
+func (p *PropertyProjection) Name() string {
+	if p.alias != "" {
+		return p.alias
+	}
+	return p.propertyJsonName
+}
+
+// GenerateClause returns the property as it appears in the SELECT statement.
+func (p *PropertyProjection) GenerateClause() string {
+	if p.alias == "" {
+		return fmt.Sprintf("%s.%s", p.source.Alias(), p.propertyJsonName)
+	}
+	return fmt.Sprintf("%s.%s AS %s", p.source.Alias(), p.propertyJsonName, p.alias)
+}
+
+func (p *PropertyProjection) GetSource() models.IModel {
+	return p.source
+}
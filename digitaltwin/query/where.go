@@ -14,16 +14,25 @@ type IWhere interface {
 }
 
 func getPropertyJsonName(source models.IModel, property string) (string, error) {
-	rm := reflect.ValueOf(source)
+	var value any = models.Unwrap(source)
+	if relationship, ok := source.(models.AliasedRelationship); ok {
+		value = relationship.Relationship()
+	}
+
+	rm := reflect.ValueOf(value)
+	if rm.Kind() != reflect.Struct {
+		return "", fmt.Errorf("model %T is not a struct", value)
+	}
+
 	field, ok := rm.Type().FieldByName(property)
 
 	if !ok {
-		return "", fmt.Errorf("field %s does not exist on model %T", property, source)
+		return "", fmt.Errorf("field %s does not exist on model %T", property, value)
 	}
 
 	jsonName, ok := field.Tag.Lookup("json")
 	if !ok {
-		return "", fmt.Errorf("field %T.%s does not have a json mapping property", source, property)
+		return "", fmt.Errorf("field %T.%s does not have a json mapping property", value, property)
 	}
 
 	jsonPropertyName := strings.Split(jsonName, ",")[0]
@@ -3,7 +3,6 @@ package query
 import (
 	"azure-adt-example/digitaltwin/models"
 	"fmt"
-	"log"
 	"reflect"
 	"strings"
 )
@@ -66,8 +65,6 @@ func (wf *WhereFunction[F]) GenerateClause() string {
 			expression = fmt.Sprintf("%s(%s.%s)", wf.function, wf.source.Alias(), wf.propertyJsonName)
 		}
 	}
-	log.Print(v)
-
 	return expression
 }
 
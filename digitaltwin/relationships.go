@@ -0,0 +1,201 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// IncomingRelationship defines a relationship which targets a twin, as returned when listing
+// the incoming relationships of that twin.
+type IncomingRelationship struct {
+	RelationshipId   string `json:"$relationshipId"`
+	SourceId         string `json:"$sourceId"`
+	RelationshipName string `json:"$relationshipName"`
+	RelationshipLink string `json:"$relationshipLink"`
+}
+
+// GetRelationship retrieves the relationship of the source twin with the given id and parses
+// it into the type R, which is either models.GenericRelationship or a type embedding it.
+func GetRelationship[R any](ctx context.Context, client *Client, sourceId string, relationshipId string) (*R, error) {
+	endpoint := client.getEndpoint(nil, "digitaltwins", sourceId, "relationships", relationshipId)
+
+	resp, err := client.sendRequest(ctx, "GET", endpoint, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer client.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newResourceError(resp, "Relationship", relationshipId, "")
+	}
+
+	return readResponse[R](resp)
+}
+
+// CreateOrReplaceRelationship creates the relationship between the source and target twins, or
+// replaces it if a relationship with the same id already exists on the source twin. The
+// relationship is either models.GenericRelationship, or a type which embeds it and adds the
+// relationship properties. If onlyIfNotExists is true then the request fails with a
+// PreconditionFailedError if the relationship already exists. Otherwise, if the relationship
+// has an ETag, the request fails with a PreconditionFailedError if it has been changed since
+// it was retrieved.
+func CreateOrReplaceRelationship[R any](ctx context.Context, client *Client, relationship R, onlyIfNotExists bool) (*R, error) {
+	identity, err := getRelationshipIdentity(relationship)
+	if err != nil {
+		return nil, err
+	}
+
+	if identity.TargetId == "" || identity.Name == "" {
+		return nil, fmt.Errorf("relationship %s must have a target id and relationship name", identity.RelationshipId)
+	}
+
+	document, err := models.NewRelationshipDocument(relationship)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialise relationship %s: %v", identity.RelationshipId, err)
+	}
+
+	header := http.Header{}
+	sentETag := ""
+	if onlyIfNotExists {
+		header.Set("If-None-Match", "*")
+	} else if identity.ETag != "" {
+		header.Set("If-Match", identity.ETag)
+		sentETag = identity.ETag
+	}
+
+	endpoint := client.getEndpoint(nil, "digitaltwins", identity.SourceId, "relationships", identity.RelationshipId)
+
+	resp, err := client.sendRequest(ctx, "PUT", endpoint, body, header)
+	if err != nil {
+		return nil, err
+	}
+	defer client.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newResourceError(resp, "Relationship", identity.RelationshipId, sentETag)
+	}
+
+	return readResponse[R](resp)
+}
+
+// UpdateRelationship applies the JsonPatch to the properties of the relationship. If the
+// relationship has an ETag then the update fails with a PreconditionFailedError if it has been
+// changed since it was retrieved.
+func UpdateRelationship[R any](ctx context.Context, client *Client, relationship R, patch JsonPatch) error {
+	identity, err := getRelationshipIdentity(relationship)
+	if err != nil {
+		return err
+	}
+
+	if len(patch) == 0 {
+		return fmt.Errorf("at least one patch operation must be specified")
+	}
+
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("unable to serialise patch for relationship %s: %v", identity.RelationshipId, err)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json-patch+json")
+	if identity.ETag != "" {
+		header.Set("If-Match", identity.ETag)
+	}
+
+	endpoint := client.getEndpoint(nil, "digitaltwins", identity.SourceId, "relationships", identity.RelationshipId)
+
+	resp, err := client.sendRequest(ctx, "PATCH", endpoint, body, header)
+	if err != nil {
+		return err
+	}
+	defer client.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return newResourceError(resp, "Relationship", identity.RelationshipId, identity.ETag)
+	}
+
+	return nil
+}
+
+// DeleteRelationship deletes the relationship. If the relationship has an ETag then the delete
+// fails with a PreconditionFailedError if it has been changed since it was retrieved.
+func DeleteRelationship[R any](ctx context.Context, client *Client, relationship R) error {
+	identity, err := getRelationshipIdentity(relationship)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	if identity.ETag != "" {
+		header.Set("If-Match", identity.ETag)
+	}
+
+	endpoint := client.getEndpoint(nil, "digitaltwins", identity.SourceId, "relationships", identity.RelationshipId)
+
+	resp, err := client.sendRequest(ctx, "DELETE", endpoint, nil, header)
+	if err != nil {
+		return err
+	}
+	defer client.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return newResourceError(resp, "Relationship", identity.RelationshipId, identity.ETag)
+	}
+
+	return nil
+}
+
+// ListRelationships retrieves the outgoing relationships of the source twin, following each
+// page of results. If relationshipName is not empty then only relationships of that name are
+// returned.
+func ListRelationships[R any](ctx context.Context, client *Client, sourceId string, relationshipName string) ([]R, error) {
+	params := url.Values{}
+	if relationshipName != "" {
+		params.Set("relationshipName", relationshipName)
+	}
+
+	endpoint := client.getEndpoint(params, "digitaltwins", sourceId, "relationships")
+
+	return listAll[R](ctx, client, endpoint, func(resp *http.Response) error {
+		return newResourceError(resp, "Twin", sourceId, "")
+	})
+}
+
+// ListIncomingRelationships retrieves the relationships from other twins which target the
+// twin, following each page of results.
+func ListIncomingRelationships(ctx context.Context, client *Client, targetId string) ([]IncomingRelationship, error) {
+	endpoint := client.getEndpoint(nil, "digitaltwins", targetId, "incomingrelationships")
+
+	return listAll[IncomingRelationship](ctx, client, endpoint, func(resp *http.Response) error {
+		return newResourceError(resp, "Twin", targetId, "")
+	})
+}
+
+// getRelationshipIdentity reads the ids and ETag of a relationship from its serialised form,
+// so that any type embedding models.GenericRelationship can be used.
+func getRelationshipIdentity(relationship any) (*models.GenericRelationship, error) {
+	content, err := json.Marshal(relationship)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialise %T: %v", relationship, err)
+	}
+
+	var identity models.GenericRelationship
+	if err = json.Unmarshal(content, &identity); err != nil {
+		return nil, fmt.Errorf("unable to read relationship identity from %T: %v", relationship, err)
+	}
+
+	if identity.RelationshipId == "" || identity.SourceId == "" {
+		return nil, fmt.Errorf("relationship of type %T must have a relationship id and source id", relationship)
+	}
+
+	return &identity, nil
+}
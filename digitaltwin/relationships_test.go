@@ -0,0 +1,253 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+type testOwnsRelationship struct {
+	models.GenericRelationship
+	OwnershipType string `json:"ownershipType"`
+}
+
+// newRelationshipServer creates a test server for the relationship APIs of the twin
+// "company01". Listing returns two pages linked by nextLink.
+func newRelationshipServer(requests *[]*http.Request, bodies *[]string) *httptest.Server {
+	var server *httptest.Server
+	server = newRecordingServer(requests, bodies, func(w http.ResponseWriter, req *http.Request, body string) {
+		switch {
+		case req.URL.Path == "/digitaltwins/company01/relationships" && req.URL.Query().Get("page") == "":
+			fmt.Fprintf(w, "{ \"value\": [ { \"$relationshipId\": \"r1\", \"$sourceId\": \"company01\", \"$targetId\": \"building01\", \"$relationshipName\": \"owns\", \"ownershipType\": \"leased\" } ], \"nextLink\": \"%s/digitaltwins/company01/relationships?api-version=2020-10-31&page=2\" }", server.URL)
+		case req.URL.Path == "/digitaltwins/company01/relationships":
+			fmt.Fprint(w, "{ \"value\": [ { \"$relationshipId\": \"r2\", \"$sourceId\": \"company01\", \"$targetId\": \"building02\", \"$relationshipName\": \"owns\" } ] }")
+		case req.URL.Path == "/digitaltwins/building01/incomingrelationships":
+			fmt.Fprint(w, "{ \"value\": [ { \"$relationshipId\": \"r1\", \"$sourceId\": \"company01\", \"$relationshipName\": \"owns\", \"$relationshipLink\": \"/digitaltwins/company01/relationships/r1\" } ] }")
+		case req.URL.Path == "/digitaltwins/company01/relationships/r1":
+			if match := req.Header.Get("If-Match"); match != "" && match != "etag1" {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				fmt.Fprint(w, "{ \"error\": { \"code\": \"PreconditionFailed\", \"message\": \"ETag does not match\" } }")
+				return
+			}
+			switch req.Method {
+			case "GET", "PUT":
+				fmt.Fprint(w, "{ \"$relationshipId\": \"r1\", \"$sourceId\": \"company01\", \"$targetId\": \"building01\", \"$relationshipName\": \"owns\", \"$etag\": \"etag1\", \"ownershipType\": \"leased\" }")
+			default:
+				w.WriteHeader(http.StatusNoContent)
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "{ \"error\": { \"code\": \"RelationshipNotFound\", \"message\": \"Relationship not found\" } }")
+		}
+	})
+	return server
+}
+
+func TestCreateOrReplaceRelationship(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newRelationshipServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	relationship := testOwnsRelationship{
+		GenericRelationship: models.GenericRelationship{
+			RelationshipId: "r1",
+			SourceId:       "company01",
+			TargetId:       "building01",
+			Name:           "owns",
+		},
+		OwnershipType: "leased",
+	}
+
+	created, err := CreateOrReplaceRelationship(context.Background(), client, relationship, true)
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if created.ETag != "etag1" || created.OwnershipType != "leased" {
+		t.Errorf("Unexpected relationship returned: %v", created)
+	}
+
+	if requests[0].Method != "PUT" || requests[0].Header.Get("If-None-Match") != "*" {
+		t.Errorf("Expected PUT with If-None-Match, but got %s with '%s'", requests[0].Method, requests[0].Header.Get("If-None-Match"))
+	}
+
+	var document map[string]any
+	_ = json.Unmarshal([]byte(bodies[0]), &document)
+
+	expected := map[string]any{"$targetId": "building01", "$relationshipName": "owns", "ownershipType": "leased"}
+	if !reflect.DeepEqual(document, expected) {
+		t.Errorf("Expected body %v, but got %v", expected, document)
+	}
+}
+
+func TestCreateOrReplaceRelationship_MissingTarget(t *testing.T) {
+	client := NewClient(nil, nil)
+
+	relationship := models.GenericRelationship{RelationshipId: "r1", SourceId: "company01", Name: "owns"}
+
+	_, err := CreateOrReplaceRelationship(context.Background(), client, relationship, false)
+
+	expectedError := "relationship r1 must have a target id and relationship name"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', but got %v", expectedError, err)
+	}
+}
+
+func TestGetRelationship(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newRelationshipServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	relationship, err := GetRelationship[testOwnsRelationship](context.Background(), client, "company01", "r1")
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if relationship.TargetId != "building01" || relationship.OwnershipType != "leased" {
+		t.Errorf("Unexpected relationship returned: %v", relationship)
+	}
+}
+
+func TestUpdateRelationship_StaleETag(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newRelationshipServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	relationship := models.GenericRelationship{RelationshipId: "r1", SourceId: "company01", ETag: "stale"}
+
+	err := UpdateRelationship(context.Background(), client, relationship, NewJsonPatch().Replace("/ownershipType", "owned"))
+
+	var preconditionFailed *PreconditionFailedError
+	if !errors.As(err, &preconditionFailed) {
+		t.Errorf("Expected PreconditionFailedError, but got %v", err)
+	}
+}
+
+func TestDeleteRelationship(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newRelationshipServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	relationship := models.GenericRelationship{RelationshipId: "r1", SourceId: "company01", ETag: "etag1"}
+
+	if err := DeleteRelationship(context.Background(), client, relationship); err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if requests[0].Method != "DELETE" || requests[0].Header.Get("If-Match") != "etag1" {
+		t.Errorf("Expected DELETE with If-Match 'etag1', but got %s with '%s'", requests[0].Method, requests[0].Header.Get("If-Match"))
+	}
+}
+
+func TestListRelationships(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newRelationshipServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	relationships, err := ListRelationships[testOwnsRelationship](context.Background(), client, "company01", "owns")
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if len(relationships) != 2 || relationships[0].OwnershipType != "leased" || relationships[1].TargetId != "building02" {
+		t.Errorf("Unexpected relationships returned: %v", relationships)
+	}
+
+	if requests[0].URL.Query().Get("relationshipName") != "owns" {
+		t.Errorf("Expected relationship name filter of 'owns', but got '%s'", requests[0].URL.Query().Get("relationshipName"))
+	}
+
+	if len(requests) != 2 {
+		t.Errorf("Expected 2 page requests, but got %d", len(requests))
+	}
+}
+
+func TestListIncomingRelationships(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newRelationshipServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	relationships, err := ListIncomingRelationships(context.Background(), client, "building01")
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	expected := []IncomingRelationship{
+		{RelationshipId: "r1", SourceId: "company01", RelationshipName: "owns", RelationshipLink: "/digitaltwins/company01/relationships/r1"},
+	}
+	if !reflect.DeepEqual(relationships, expected) {
+		t.Errorf("Expected %v, but got %v", expected, relationships)
+	}
+}
+
+func TestListIncomingRelationships_NotFound(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newRelationshipServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	_, err := ListIncomingRelationships(context.Background(), client, "missing")
+	if err == nil || !strings.Contains(err.Error(), "Relationship not found") {
+		t.Errorf("Expected not found error, but got %v", err)
+	}
+}
+
+func TestListRelationships_ForeignNextLink(t *testing.T) {
+	var foreignRequests int32
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&foreignRequests, 1)
+		fmt.Fprint(w, "{ \"value\": [] }")
+	}))
+	defer foreign.Close()
+
+	var requests []*http.Request
+	var bodies []string
+	server := newRecordingServer(&requests, &bodies, func(w http.ResponseWriter, req *http.Request, body string) {
+		fmt.Fprintf(w, "{ \"value\": [], \"nextLink\": \"%s/digitaltwins/company01/relationships?page=2\" }", foreign.URL)
+	})
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	_, err := ListRelationships[testOwnsRelationship](context.Background(), client, "company01", "owns")
+	if err == nil || !strings.Contains(err.Error(), "is not on the instance host") {
+		t.Errorf("Expected the next link to be rejected, but got %v", err)
+	}
+
+	if atomic.LoadInt32(&foreignRequests) != 0 {
+		t.Errorf("Expected no requests to the other host, but got %d", foreignRequests)
+	}
+}
@@ -2,6 +2,7 @@ package digitaltwin
 
 import (
 	"encoding/json"
+	"fmt"
 )
 
 // QueryError defines the response received from Azure Digital Twin if a query
@@ -14,17 +15,41 @@ type QueryError struct {
 type ErrorDetail struct {
 	Code    string `json:"code"`
 	Message string `json:"message"`
+
+	// Details contains the errors which caused this error, such as each invalid property.
+	Details []ErrorDetail `json:"details,omitempty"`
+
+	// InnerError contains more specific error codes, nested from the least to most specific.
+	InnerError *InnerError `json:"innererror,omitempty"`
 }
 
-type digitalTwinResults []map[string]json.RawMessage
+// InnerError defines a more specific code for an Azure Digital Twin error.
+type InnerError struct {
+	Code       string      `json:"code"`
+	InnerError *InnerError `json:"innererror,omitempty"`
+}
+
+// InnermostCode returns the most specific code of the error, which is the Code if there are no
+// inner errors.
+func (d ErrorDetail) InnermostCode() string {
+	code := d.Code
+	for inner := d.InnerError; inner != nil; inner = inner.InnerError {
+		if inner.Code != "" {
+			code = inner.Code
+		}
+	}
+	return code
+}
+
+type digitalTwinResults []json.RawMessage
 
 // QueryResultGeneric defines a successful response message from Azure Digital Twin.
 // The response includes a ContinuationToken for paging results. The Results are
 // left as their JSON message values so that each may be Unmarshalled to the correct
 // type.
 type QueryResultGeneric struct {
-	// Results contains an array of JSON objects where each object represents a specific
-	// models.IModel type.
+	// Results contains an array of JSON objects where each object is a row of the query,
+	// containing the projected twins and properties.
 	Results digitalTwinResults `json:"value"`
 
 	// ContinuationToken is the value required to be sent back to the query API to
@@ -36,3 +61,41 @@ type QueryResultGeneric struct {
 func (q *QueryResultGeneric) HasContinuationToken() bool {
 	return len(q.ContinuationToken) != 0
 }
+
+// listResult defines a page of results returned by the Azure Digital Twin list APIs, such as
+// listing relationships. Unlike queries, the next page is retrieved from NextLink.
+type listResult[T any] struct {
+	Value    []T    `json:"value"`
+	NextLink string `json:"nextLink"`
+}
+
+// PreconditionFailedError is returned when a write is rejected because the ETag supplied with
+// the request no longer matches the resource, or because the resource already exists when it
+// was only to be created.
+type PreconditionFailedError struct {
+	// Id of the twin or relationship which was being written.
+	Id string
+
+	// ETag which was sent with the request, empty if the request used "If-None-Match".
+	ETag string
+
+	// ErrorDetail contains the error returned by the Azure Digital Twin.
+	ErrorDetail ErrorDetail
+
+	// Response contains the details of the failed response.
+	Response *ResponseError
+}
+
+func (e *PreconditionFailedError) Error() string {
+	if e.ETag == "" {
+		return fmt.Sprintf("precondition failed for %s: %s", e.Id, e.ErrorDetail.Message)
+	}
+	return fmt.Sprintf("precondition failed for %s with etag %s: %s", e.Id, e.ETag, e.ErrorDetail.Message)
+}
+
+func (e *PreconditionFailedError) Unwrap() error {
+	if e.Response == nil {
+		return nil
+	}
+	return e.Response
+}
@@ -0,0 +1,86 @@
+package digitaltwin
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// TelemetryOptions defines the optional values sent with a telemetry message.
+type TelemetryOptions struct {
+	// MessageId uniquely identifies the message and is used by Azure Digital Twin to remove
+	// duplicates. If empty then a random id is generated.
+	MessageId string
+
+	// SourceTime is when the telemetry was observed. If zero then it is not sent.
+	SourceTime time.Time
+}
+
+// PublishTelemetry sends telemetry for the twin to any routes listening for telemetry
+// events. The payload can be any value which serialises to a JSON object, such as a struct
+// holding an occupancy reading for a rec33.Level.
+func (c *Client) PublishTelemetry(ctx context.Context, twinId string, payload any, options *TelemetryOptions) error {
+	endpoint := c.getEndpoint(nil, "digitaltwins", twinId, "telemetry")
+	return c.publishTelemetry(ctx, endpoint, twinId, payload, options)
+}
+
+// PublishComponentTelemetry sends telemetry for a component of the twin to any routes
+// listening for telemetry events.
+func (c *Client) PublishComponentTelemetry(ctx context.Context, twinId string, componentName string, payload any, options *TelemetryOptions) error {
+	endpoint := c.getEndpoint(nil, "digitaltwins", twinId, "components", componentName, "telemetry")
+	return c.publishTelemetry(ctx, endpoint, fmt.Sprintf("%s/%s", twinId, componentName), payload, options)
+}
+
+// publishTelemetry sends the payload to a telemetry endpoint. The same message id is used if
+// the request is retried, so the telemetry is not duplicated.
+func (c *Client) publishTelemetry(ctx context.Context, endpoint string, id string, payload any, options *TelemetryOptions) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("unable to serialise telemetry for %s: %v", id, err)
+	}
+
+	if options == nil {
+		options = &TelemetryOptions{}
+	}
+
+	messageId := options.MessageId
+	if messageId == "" {
+		if messageId, err = newMessageId(); err != nil {
+			return err
+		}
+	}
+
+	header := http.Header{}
+	header.Set("Message-Id", messageId)
+	if !options.SourceTime.IsZero() {
+		header.Set("Telemetry-Source-Time", options.SourceTime.UTC().Format(time.RFC3339Nano))
+	}
+
+	resp, err := c.sendRequest(ctx, "POST", endpoint, body, header)
+	if err != nil {
+		return err
+	}
+	defer c.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return newResourceError(resp, "Twin", id, "")
+	}
+
+	return nil
+}
+
+// newMessageId generates a random (version 4) UUID to identify a telemetry message.
+func newMessageId() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", fmt.Errorf("unable to generate message id: %v", err)
+	}
+
+	id[6] = (id[6] & 0x0f) | 0x40
+	id[8] = (id[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:16]), nil
+}
@@ -0,0 +1,116 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/azuread"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testOccupancy struct {
+	PersonOccupancy int32 `json:"personOccupancy"`
+}
+
+func newTelemetryServer(requests *[]*http.Request, bodies *[]string) *httptest.Server {
+	return newRecordingServer(requests, bodies, func(w http.ResponseWriter, req *http.Request, body string) {
+		if strings.HasPrefix(req.URL.Path, "/digitaltwins/level01/") && strings.HasSuffix(req.URL.Path, "/telemetry") {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, "{ \"error\": { \"code\": \"DigitalTwinNotFound\", \"message\": \"There is no digital twin instance that exists with the ID\" } }")
+	})
+}
+
+func TestClient_PublishTelemetry(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newTelemetryServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	err := client.PublishTelemetry(context.Background(), "level01", testOccupancy{PersonOccupancy: 12}, nil)
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if requests[0].URL.Path != "/digitaltwins/level01/telemetry" {
+		t.Errorf("Unexpected telemetry path '%s'", requests[0].URL.Path)
+	}
+
+	if bodies[0] != `{"personOccupancy":12}` {
+		t.Errorf("Unexpected telemetry body '%s'", bodies[0])
+	}
+
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	if !uuidPattern.MatchString(requests[0].Header.Get("Message-Id")) {
+		t.Errorf("Expected a generated UUID message id, but got '%s'", requests[0].Header.Get("Message-Id"))
+	}
+
+	if requests[0].Header.Get("Telemetry-Source-Time") != "" {
+		t.Errorf("Expected no source time, but got '%s'", requests[0].Header.Get("Telemetry-Source-Time"))
+	}
+}
+
+func TestClient_PublishComponentTelemetry(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newTelemetryServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	options := TelemetryOptions{
+		MessageId:  "message1",
+		SourceTime: time.Date(2022, 6, 22, 9, 9, 17, 0, time.UTC),
+	}
+
+	err := client.PublishComponentTelemetry(context.Background(), "level01", "sensor", map[string]any{"temperature": 21.5}, &options)
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if requests[0].URL.Path != "/digitaltwins/level01/components/sensor/telemetry" {
+		t.Errorf("Unexpected telemetry path '%s'", requests[0].URL.Path)
+	}
+
+	if requests[0].Header.Get("Message-Id") != "message1" {
+		t.Errorf("Expected message id 'message1', but got '%s'", requests[0].Header.Get("Message-Id"))
+	}
+
+	if requests[0].Header.Get("Telemetry-Source-Time") != "2022-06-22T09:09:17Z" {
+		t.Errorf("Unexpected source time '%s'", requests[0].Header.Get("Telemetry-Source-Time"))
+	}
+}
+
+func TestClient_PublishTelemetry_UnknownTwin(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newTelemetryServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	err := client.PublishTelemetry(context.Background(), "level99", testOccupancy{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "Twin: level99") {
+		t.Errorf("Expected not found error for level99, but got %v", err)
+	}
+}
+
+func TestClient_PublishTelemetry_InvalidPayload(t *testing.T) {
+	client := NewClient(&azuread.TwinConfiguration{}, nil)
+
+	err := client.PublishTelemetry(context.Background(), "level01", make(chan int), nil)
+	if err == nil || !strings.Contains(err.Error(), "unable to serialise telemetry for level01") {
+		t.Errorf("Expected serialisation error, but got %v", err)
+	}
+}
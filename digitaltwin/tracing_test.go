@@ -0,0 +1,116 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/azuread"
+	"azure-adt-example/digitaltwin/models/rec33"
+	"azure-adt-example/digitaltwin/query"
+	"azure-adt-example/tracing"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestClient_Tracing(t *testing.T) {
+	var traceParents []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		traceParents = append(traceParents, req.Header.Get(tracing.TraceParentHeader))
+
+		var body struct {
+			ContinuationToken string `json:"continuationToken"`
+		}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+
+		w.Header().Set("query-charge", "2.5")
+		if body.ContinuationToken == "" {
+			fmt.Fprint(w, "{ \"value\": [ { \"building\": { \"$dtId\": \"building01\" } } ], \"continuationToken\": \"page2\" }")
+			return
+		}
+		fmt.Fprint(w, "{ \"value\": [ { \"building\": { \"$dtId\": \"building02\" } } ], \"continuationToken\": null }")
+	}))
+	defer server.Close()
+
+	serverUrl, _ := url.Parse(server.URL)
+	exporter := tracing.NewInMemoryExporter()
+	client := NewClient(&azuread.TwinConfiguration{URL: *serverUrl}, &staticCredential{})
+	client.TokenCache = azuread.NewTokenCache(azuread.DefaultRefreshMargin)
+	client.Tracer = exporter
+	client.Meter = exporter
+
+	results, err := ExecuteBuilderCtx[rec33.Building](context.Background(), client, query.NewBuilder(rec33.Building{}, false, false))
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, but got %d", len(results))
+	}
+
+	builders := exporter.SpansNamed(tracing.SpanExecuteBuilder)
+	if len(builders) != 1 {
+		t.Fatalf("Expected 1 %s span, but got %d", tracing.SpanExecuteBuilder, len(builders))
+	}
+	builder := builders[0]
+	if builder.Attributes[tracing.AttributeResultCount] != 2 || builder.Attributes[tracing.AttributePage] != 2 || builder.Attributes[tracing.AttributeQuery] == "" {
+		t.Errorf("Unexpected %s attributes %v", tracing.SpanExecuteBuilder, builder.Attributes)
+	}
+
+	pages := exporter.SpansNamed(tracing.SpanFetchPage)
+	if len(pages) != 2 {
+		t.Fatalf("Expected 2 %s spans, but got %d", tracing.SpanFetchPage, len(pages))
+	}
+	for i, page := range pages {
+		if page.ParentSpanId != builder.SpanContext.SpanId {
+			t.Errorf("Expected page %d to be a child of %s", i+1, tracing.SpanExecuteBuilder)
+		}
+		if page.Attributes[tracing.AttributePage] != i+1 || page.Attributes[tracing.AttributeQueryCharge] != 2.5 || page.Attributes[tracing.AttributeResultCount] != 1 {
+			t.Errorf("Unexpected attributes for page %d: %v", i+1, page.Attributes)
+		}
+	}
+
+	if tokens := exporter.SpansNamed(tracing.SpanAcquireToken); len(tokens) != 1 || tokens[0].SpanContext.TraceId != builder.SpanContext.TraceId {
+		t.Errorf("Expected 1 %s span within the trace, but got %v", tracing.SpanAcquireToken, tokens)
+	}
+
+	requests := exporter.SpansNamed("HTTP POST")
+	if len(requests) != 2 || len(traceParents) != 2 {
+		t.Fatalf("Expected 2 requests, but got %d spans and %d requests", len(requests), len(traceParents))
+	}
+	for i, request := range requests {
+		if traceParents[i] != request.SpanContext.TraceParent() {
+			t.Errorf("Expected traceparent %s, but got %s", request.SpanContext.TraceParent(), traceParents[i])
+		}
+		if request.Attributes[tracing.AttributeStatus] != http.StatusOK {
+			t.Errorf("Unexpected status attribute %v", request.Attributes[tracing.AttributeStatus])
+		}
+	}
+
+	if exporter.Sum(tracing.MetricRequests) != 2 || exporter.Sum(tracing.MetricErrors) != 0 || exporter.Sum(tracing.MetricQueryCharge) != 5 {
+		t.Errorf("Unexpected metrics: %v requests, %v errors, %v query charge",
+			exporter.Sum(tracing.MetricRequests), exporter.Sum(tracing.MetricErrors), exporter.Sum(tracing.MetricQueryCharge))
+	}
+}
+
+func TestExecutePage_Tracing(t *testing.T) {
+	var requested []string
+	server := newPagedServer(2, &requested)
+	defer server.Close()
+
+	exporter := tracing.NewInMemoryExporter()
+	client := newTestClient(server)
+	client.Tracer = exporter
+
+	if _, err := ExecutePage[rec33.Building](context.Background(), client, query.NewBuilder(rec33.Building{}, false, false), nil); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	pages := exporter.SpansNamed(tracing.SpanExecutePage)
+	if len(pages) != 1 {
+		t.Fatalf("Expected 1 %s span, but got %d", tracing.SpanExecutePage, len(pages))
+	}
+	if fetches := exporter.SpansNamed(tracing.SpanFetchPage); len(fetches) != 1 || fetches[0].ParentSpanId != pages[0].SpanContext.SpanId {
+		t.Errorf("Expected 1 %s span within %s, but got %v", tracing.SpanFetchPage, tracing.SpanExecutePage, fetches)
+	}
+}
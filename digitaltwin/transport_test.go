@@ -0,0 +1,169 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/models/rec33"
+	"azure-adt-example/digitaltwin/query"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// newLargePage creates the body of a page of query results containing the number of buildings.
+func newLargePage(rows int, continuationToken string) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("{ \"value\": [")
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		fmt.Fprintf(&buffer, "{ \"building\": { \"$dtId\": \"building%04d\", \"$etag\": \"W/\\\"etag%d\\\"\", \"$metadata\": { \"$model\": %q }, \"name\": \"Building %d\" } }", i, i, rec33.Building{}.Model(), i)
+	}
+	fmt.Fprintf(&buffer, "], \"continuationToken\": %q }", continuationToken)
+	return buffer.Bytes()
+}
+
+// newCompressingServer creates a test server which returns the number of pages of results,
+// compressed with gzip if the request accepts it, and counts the connections made to it.
+func newCompressingServer(pages int, rows int, connections *int32) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.RequestURI == "/tenant1/oauth2/token" && req.Method == "POST" {
+			fmt.Fprint(w, "{ \"token_type\": \"Bearer\", \"expires_in\": 3599, \"access_token\": \"abc123\" }")
+			return
+		}
+
+		var body struct {
+			ContinuationToken string `json:"continuationToken"`
+		}
+		_ = json.NewDecoder(req.Body).Decode(&body)
+
+		page := 1
+		if body.ContinuationToken != "" {
+			_, _ = fmt.Sscanf(body.ContinuationToken, "page%d", &page)
+		}
+		nextToken := ""
+		if page < pages {
+			nextToken = fmt.Sprintf("page%d", page+1)
+		}
+
+		content := newLargePage(rows, nextToken)
+		if !strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+			_, _ = w.Write(content)
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+		writer := gzip.NewWriter(w)
+		_, _ = writer.Write(content)
+		_ = writer.Close()
+	}))
+
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(connections, 1)
+		}
+	}
+	server.Start()
+
+	return server
+}
+
+func TestClient_CompressedPagesReuseConnection(t *testing.T) {
+	var connections int32
+	server := newCompressingServer(3, 50, &connections)
+	defer server.Close()
+
+	client := newTestClient(server)
+	client.TokenCache = nil
+
+	results, err := ExecuteBuilder[rec33.Building](client, query.NewBuilder(rec33.Building{}, false, false))
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if len(results) != 150 || results[149].Name != "Building 49" {
+		t.Errorf("Expected 150 decompressed buildings, but got %d", len(results))
+	}
+
+	if connections != 1 {
+		t.Errorf("Expected every request to reuse 1 connection, but %d were made", connections)
+	}
+}
+
+func BenchmarkDecodeQueryPage(b *testing.B) {
+	content := newLargePage(1000, "page2")
+
+	// Buffered is the previous approach of reading the whole body, parsing each row into a map
+	// of its columns, then parsing the twin from the map. Streaming parses the page as it is
+	// read, then decodes each row straight into the twin.
+	b.Run("Buffered", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			data, err := ioutil.ReadAll(bytes.NewReader(content))
+			if err != nil {
+				b.Fatal(err)
+			}
+			var page queryResultBody
+			if err = json.Unmarshal(data, &page); err != nil {
+				b.Fatal(err)
+			}
+			for _, row := range page.Results {
+				var building rec33.Building
+				if err = json.Unmarshal(row["building"], &building); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+
+	b.Run("Streaming", func(b *testing.B) {
+		decode, err := newRowDecoder[rec33.Building](query.NewBuilder(rec33.Building{}, false, false))
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			page, err := decodeQueryPage(bytes.NewReader(content))
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, row := range page.Results {
+				if _, err = decode(row); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+}
+
+func BenchmarkPager(b *testing.B) {
+	var connections int32
+	server := newCompressingServer(1, 1000, &connections)
+	defer server.Close()
+
+	client := newTestClient(server)
+	builder := query.NewBuilder(rec33.Building{}, false, false)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		pager, err := NewPager[rec33.Building](client, builder)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for pager.Next(context.Background()) {
+		}
+		if err = pager.Err(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
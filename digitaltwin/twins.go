@@ -0,0 +1,170 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/models"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// GetTwin retrieves the twin with the given id and parses it into the models.IModel type.
+func GetTwin[T models.IModel](ctx context.Context, client *Client, id string) (*T, error) {
+	endpoint := client.getEndpoint(nil, "digitaltwins", id)
+
+	resp, err := client.sendRequest(ctx, "GET", endpoint, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer client.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newResourceError(resp, "Twin", id, "")
+	}
+
+	return readResponse[T](resp)
+}
+
+// CreateOrReplaceTwin creates the twin, or replaces it if a twin with the same id already
+// exists. If onlyIfNotExists is true then the request fails with a PreconditionFailedError
+// if the twin already exists. Otherwise, if the twin has an ETag, the request fails with a
+// PreconditionFailedError if the twin has been changed since it was retrieved. The twin as
+// stored by the Azure Digital Twin is returned.
+func CreateOrReplaceTwin[T models.IModel](ctx context.Context, client *Client, twin T, onlyIfNotExists bool) (*T, error) {
+	identity, err := getTwinIdentity(twin)
+	if err != nil {
+		return nil, err
+	}
+
+	document, err := models.NewTwinDocument(twin)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialise twin %s: %v", identity.ExternalId, err)
+	}
+
+	header := http.Header{}
+	sentETag := ""
+	if onlyIfNotExists {
+		header.Set("If-None-Match", "*")
+	} else if identity.ETag != "" {
+		header.Set("If-Match", identity.ETag)
+		sentETag = identity.ETag
+	}
+
+	endpoint := client.getEndpoint(nil, "digitaltwins", identity.ExternalId)
+
+	resp, err := client.sendRequest(ctx, "PUT", endpoint, body, header)
+	if err != nil {
+		return nil, err
+	}
+	defer client.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newResourceError(resp, "Twin", identity.ExternalId, sentETag)
+	}
+
+	return readResponse[T](resp)
+}
+
+// UpdateTwin applies the JsonPatch to the twin. If the twin has an ETag then the update fails
+// with a PreconditionFailedError if the twin has been changed since it was retrieved.
+func UpdateTwin[T models.IModel](ctx context.Context, client *Client, twin T, patch JsonPatch) error {
+	identity, err := getTwinIdentity(twin)
+	if err != nil {
+		return err
+	}
+
+	if len(patch) == 0 {
+		return fmt.Errorf("at least one patch operation must be specified")
+	}
+
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return fmt.Errorf("unable to serialise patch for twin %s: %v", identity.ExternalId, err)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json-patch+json")
+	if identity.ETag != "" {
+		header.Set("If-Match", identity.ETag)
+	}
+
+	endpoint := client.getEndpoint(nil, "digitaltwins", identity.ExternalId)
+
+	resp, err := client.sendRequest(ctx, "PATCH", endpoint, body, header)
+	if err != nil {
+		return err
+	}
+	defer client.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return newResourceError(resp, "Twin", identity.ExternalId, identity.ETag)
+	}
+
+	return nil
+}
+
+// DeleteTwin deletes the twin. If the twin has an ETag then the delete fails with a
+// PreconditionFailedError if the twin has been changed since it was retrieved. All
+// relationships of the twin must be deleted first.
+func DeleteTwin[T models.IModel](ctx context.Context, client *Client, twin T) error {
+	identity, err := getTwinIdentity(twin)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	if identity.ETag != "" {
+		header.Set("If-Match", identity.ETag)
+	}
+
+	endpoint := client.getEndpoint(nil, "digitaltwins", identity.ExternalId)
+
+	resp, err := client.sendRequest(ctx, "DELETE", endpoint, nil, header)
+	if err != nil {
+		return err
+	}
+	defer client.closeBody(resp.Body)
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return newResourceError(resp, "Twin", identity.ExternalId, identity.ETag)
+	}
+
+	return nil
+}
+
+// getTwinIdentity reads the id and ETag of a twin from its serialised form, so that any
+// models.IModel which embeds models.GenericModel can be used.
+func getTwinIdentity(twin models.IModel) (*models.GenericModel, error) {
+	content, err := json.Marshal(twin)
+	if err != nil {
+		return nil, fmt.Errorf("unable to serialise %T: %v", twin, err)
+	}
+
+	var identity models.GenericModel
+	if err = json.Unmarshal(content, &identity); err != nil {
+		return nil, fmt.Errorf("unable to read twin identity from %T: %v", twin, err)
+	}
+
+	if identity.ExternalId == "" {
+		return nil, fmt.Errorf("twin of type %T does not have an id", twin)
+	}
+
+	return &identity, nil
+}
+
+// newResourceError creates the error for a failed request against a twin or relationship,
+// using a PreconditionFailedError if the request was rejected because of its ETag.
+func newResourceError(resp *http.Response, resource string, id string, etag string) error {
+	responseError := newResponseError(resp, resource, id)
+
+	if resp.StatusCode == http.StatusPreconditionFailed {
+		return &PreconditionFailedError{Id: id, ETag: etag, ErrorDetail: responseError.ErrorDetail, Response: responseError}
+	}
+
+	return responseError
+}
@@ -0,0 +1,258 @@
+package digitaltwin
+
+import (
+	"azure-adt-example/digitaltwin/models"
+	"azure-adt-example/digitaltwin/models/rec33"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTwinServer creates a test server which stores a single building twin, honouring the
+// If-Match and If-None-Match headers against its ETag.
+func newTwinServer(requests *[]*http.Request, bodies *[]string) *httptest.Server {
+	etag := "W/\"etag1\""
+	exists := true
+
+	return newRecordingServer(requests, bodies, func(w http.ResponseWriter, req *http.Request, body string) {
+		if !strings.HasPrefix(req.URL.Path, "/digitaltwins/building01") {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "{ \"error\": { \"code\": \"DigitalTwinNotFound\", \"message\": \"There is no digital twin instance that exists with the ID\" } }")
+			return
+		}
+
+		if match := req.Header.Get("If-Match"); match != "" && match != etag {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			fmt.Fprint(w, "{ \"error\": { \"code\": \"PreconditionFailed\", \"message\": \"ETag does not match\" } }")
+			return
+		}
+
+		if req.Header.Get("If-None-Match") == "*" && exists {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			fmt.Fprint(w, "{ \"error\": { \"code\": \"PreconditionFailed\", \"message\": \"Twin already exists\" } }")
+			return
+		}
+
+		switch req.Method {
+		case "GET", "PUT":
+			fmt.Fprintf(w, "{ \"$dtId\": \"building01\", \"$etag\": %q, \"$metadata\": { \"$model\": %q }, \"name\": \"Building 1\" }", etag, rec33.Building{}.Model())
+		case "PATCH", "DELETE":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+}
+
+func TestGetTwin(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newTwinServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	building, err := GetTwin[rec33.Building](context.Background(), client, "building01")
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if building.ExternalId != "building01" || building.Name != "Building 1" || building.ETag != "W/\"etag1\"" {
+		t.Errorf("Unexpected twin returned: %v", building)
+	}
+
+	expectedModel := rec33.Building{}.Model()
+	if building.TwinModelType() != expectedModel {
+		t.Errorf("Expected model '%s', but got '%s'", expectedModel, building.TwinModelType())
+	}
+}
+
+func TestGetTwin_NotFound(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newTwinServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	_, err := GetTwin[rec33.Building](context.Background(), client, "missing twin")
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected not found error, but got %v", err)
+	}
+
+	if len(requests) != 1 || requests[0].URL.EscapedPath() != "/digitaltwins/missing%20twin" {
+		t.Errorf("Expected twin id to be escaped in the request path")
+	}
+}
+
+func TestCreateOrReplaceTwin(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newTwinServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	twin := rec33.Building{
+		GenericModel: models.GenericModel{ExternalId: "building01", ETag: "W/\"etag1\""},
+		Name:         "Building 1",
+	}
+
+	created, err := CreateOrReplaceTwin(context.Background(), client, twin, false)
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	if created.Name != "Building 1" {
+		t.Errorf("Expected returned twin to be 'Building 1', but got '%s'", created.Name)
+	}
+
+	if requests[0].Header.Get("If-Match") != "W/\"etag1\"" {
+		t.Errorf("Expected If-Match header to contain the twin ETag, but got '%s'", requests[0].Header.Get("If-Match"))
+	}
+
+	var document map[string]any
+	_ = json.Unmarshal([]byte(bodies[0]), &document)
+
+	expectedMetadata := map[string]any{"$model": rec33.Building{}.Model()}
+	if !reflect.DeepEqual(document["$metadata"], expectedMetadata) {
+		t.Errorf("Expected metadata %v, but got %v", expectedMetadata, document["$metadata"])
+	}
+
+	if _, ok := document["$etag"]; ok {
+		t.Error("Expected $etag to be removed from the request body")
+	}
+}
+
+func TestCreateOrReplaceTwin_AlreadyExists(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newTwinServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	twin := rec33.Building{GenericModel: models.GenericModel{ExternalId: "building01"}, Name: "Building 1"}
+
+	_, err := CreateOrReplaceTwin(context.Background(), client, twin, true)
+
+	var preconditionFailed *PreconditionFailedError
+	if !errors.As(err, &preconditionFailed) {
+		t.Logf("Expected PreconditionFailedError, but got %v", err)
+		t.FailNow()
+	}
+
+	if preconditionFailed.Id != "building01" || preconditionFailed.ErrorDetail.Code != "PreconditionFailed" {
+		t.Errorf("Unexpected error content: %v", preconditionFailed)
+	}
+
+	if requests[0].Header.Get("If-None-Match") != "*" {
+		t.Errorf("Expected If-None-Match header of '*', but got '%s'", requests[0].Header.Get("If-None-Match"))
+	}
+}
+
+func TestCreateOrReplaceTwin_NoId(t *testing.T) {
+	client := NewClient(nil, nil)
+
+	_, err := CreateOrReplaceTwin(context.Background(), client, rec33.Building{Name: "Building 1"}, false)
+
+	expectedError := "twin of type rec33.Building does not have an id"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', but got %v", expectedError, err)
+	}
+}
+
+func TestUpdateTwin(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newTwinServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	twin := rec33.Building{GenericModel: models.GenericModel{ExternalId: "building01", ETag: "W/\"etag1\""}}
+	patch := NewJsonPatch().Replace("/name", "Renamed").Remove("/logo")
+
+	if err := UpdateTwin(context.Background(), client, twin, patch); err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+
+	expectedBody := `[{"op":"replace","path":"/name","value":"Renamed"},{"op":"remove","path":"/logo"}]`
+	if bodies[0] != expectedBody {
+		t.Errorf("Expected body '%s', but got '%s'", expectedBody, bodies[0])
+	}
+
+	if requests[0].Header.Get("Content-Type") != "application/json-patch+json" {
+		t.Errorf("Expected JSON patch content type, but got '%s'", requests[0].Header.Get("Content-Type"))
+	}
+}
+
+func TestUpdateTwin_StaleETag(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newTwinServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	twin := rec33.Building{GenericModel: models.GenericModel{ExternalId: "building01", ETag: "W/\"stale\""}}
+
+	err := UpdateTwin(context.Background(), client, twin, NewJsonPatch().Replace("/name", "Renamed"))
+
+	var preconditionFailed *PreconditionFailedError
+	if !errors.As(err, &preconditionFailed) {
+		t.Logf("Expected PreconditionFailedError, but got %v", err)
+		t.FailNow()
+	}
+
+	if preconditionFailed.ETag != "W/\"stale\"" {
+		t.Errorf("Expected error to contain the stale ETag, but got '%s'", preconditionFailed.ETag)
+	}
+}
+
+func TestDeleteTwin(t *testing.T) {
+	var requests []*http.Request
+	var bodies []string
+	server := newTwinServer(&requests, &bodies)
+	defer server.Close()
+
+	client := newTestClient(server)
+
+	twin := rec33.Building{GenericModel: models.GenericModel{ExternalId: "building01"}}
+
+	if err := DeleteTwin(context.Background(), client, twin); err != nil {
+		t.Errorf("Expected nil error, but got %v", err)
+	}
+
+	if requests[0].Method != "DELETE" || requests[0].Header.Get("If-Match") != "" {
+		t.Errorf("Expected an unconditional DELETE request, but got %s with If-Match '%s'", requests[0].Method, requests[0].Header.Get("If-Match"))
+	}
+}
+
+func TestPropertyPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		properties []string
+		expected   string
+	}{
+		{"SingleProperty", []string{"name"}, "/name"},
+		{"NestedProperty", []string{"address", "city"}, "/address/city"},
+		{"EscapedProperty", []string{"a/b", "c~d"}, "/a~1b/c~0d"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := PropertyPath(test.properties...)
+			if actual != test.expected {
+				t.Errorf("Expected '%s', but got '%s'", test.expected, actual)
+			}
+		})
+	}
+}
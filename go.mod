@@ -2,4 +2,7 @@ module azure-adt-example
 
 go 1.18
 
-require github.com/subosito/gotenv v1.4.0
+require (
+	github.com/subosito/gotenv v1.4.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Level defines the severity of a log entry.
+type Level int
+
+const (
+	// LevelDebug is used for detail which is only useful when diagnosing a problem, such as the
+	// text of each query.
+	LevelDebug Level = iota + 1
+
+	// LevelInfo is used for the normal operation of the library, such as acquiring a token.
+	LevelInfo
+
+	// LevelWarn is used for problems which the library recovered from.
+	LevelWarn
+
+	// LevelError is used for failures which are also returned to the caller.
+	LevelError
+)
+
+func (l Level) String() string {
+	levels := []string{"DEBUG", "INFO", "WARN", "ERROR"}
+	if !l.IsValid() {
+		return fmt.Sprintf("Level(%d)", l)
+	}
+	return levels[l-1]
+}
+
+func (l Level) IsValid() bool {
+	switch l {
+	case LevelDebug, LevelInfo, LevelWarn, LevelError:
+		return true
+	}
+	return false
+}
+
+// The keys of the fields logged by the library.
+const (
+	FieldQuery    = "query"
+	FieldPage     = "page"
+	FieldDuration = "duration"
+	FieldStatus   = "status"
+	FieldCount    = "count"
+	FieldMethod   = "method"
+	FieldURL      = "url"
+	FieldAudience = "audience"
+	FieldError    = "error"
+)
+
+// Field is a named value added to a log entry.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// F creates a Field with the given key and value.
+func F(key string, value any) Field {
+	return Field{Key: key, Value: value}
+}
+
+// Logger receives the log entries of the library. Implementations must be safe for concurrent
+// use, and can adapt the entries to a structured logging library such as zap or slog.
+type Logger interface {
+	Log(level Level, message string, fields ...Field)
+}
+
+// Discard is a Logger which ignores every entry. It is used when no Logger has been configured.
+var Discard Logger = discard{}
+
+type discard struct{}
+
+func (discard) Log(Level, string, ...Field) {}
+
+// OrDiscard returns the logger, or Discard if it is nil.
+func OrDiscard(logger Logger) Logger {
+	if logger == nil {
+		return Discard
+	}
+	return logger
+}
+
+// StdLogger adapts a log.Logger from the standard library, writing each entry as a single line
+// such as:
+//
+//	[INFO] acquired access token audience=https://digitaltwins.azure.net/.default duration=120ms
+type StdLogger struct {
+	// Logger which the entries are written to. If nil then the standard logger is used.
+	Logger *log.Logger
+
+	// MinLevel is the lowest level which is written. If not set then every entry is written.
+	MinLevel Level
+}
+
+// NewStdLogger creates a StdLogger which writes entries of at least minLevel to the logger.
+func NewStdLogger(logger *log.Logger, minLevel Level) *StdLogger {
+	return &StdLogger{Logger: logger, MinLevel: minLevel}
+}
+
+func (s *StdLogger) Log(level Level, message string, fields ...Field) {
+	if level < s.MinLevel {
+		return
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("[%s] %s", level, message))
+	for _, field := range fields {
+		builder.WriteString(fmt.Sprintf(" %s=%s", field.Key, formatValue(field.Value)))
+	}
+
+	logger := s.Logger
+	if logger == nil {
+		logger = log.Default()
+	}
+	logger.Print(builder.String())
+}
+
+// formatValue converts a field value into text, quoting it if it would otherwise be ambiguous.
+func formatValue(value any) string {
+	var text string
+	switch v := value.(type) {
+	case string:
+		text = v
+	case time.Duration:
+		return v.String()
+	case error:
+		text = v.Error()
+	default:
+		text = fmt.Sprint(v)
+	}
+
+	if text == "" || strings.ContainsAny(text, " \t\n\"=") {
+		return fmt.Sprintf("%q", text)
+	}
+	return text
+}
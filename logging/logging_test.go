@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStdLogger_Log(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := NewStdLogger(log.New(&buffer, "", 0), LevelInfo)
+
+	logger.Log(LevelDebug, "ignored", F(FieldQuery, "SELECT * FROM digitaltwins"))
+	logger.Log(LevelInfo, "query page retrieved", F(FieldQuery, "SELECT * FROM digitaltwins"), F(FieldPage, 2), F(FieldDuration, 1500*time.Millisecond), F(FieldStatus, 200))
+	logger.Log(LevelError, "failed", F(FieldError, errors.New("not found")), F("empty", ""))
+
+	expected := "[INFO] query page retrieved query=\"SELECT * FROM digitaltwins\" page=2 duration=1.5s status=200\n" +
+		"[ERROR] failed error=\"not found\" empty=\"\"\n"
+	if buffer.String() != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, buffer.String())
+	}
+}
+
+func TestOrDiscard(t *testing.T) {
+	if OrDiscard(nil) != Discard {
+		t.Error("Expected Discard for a nil logger")
+	}
+
+	logger := NewStdLogger(nil, LevelWarn)
+	if OrDiscard(logger) != logger {
+		t.Error("Expected the given logger to be returned")
+	}
+}
+
+func TestLevel_String(t *testing.T) {
+	if LevelWarn.String() != "WARN" {
+		t.Errorf("Expected WARN, but got %s", LevelWarn)
+	}
+
+	var buffer bytes.Buffer
+	logger := &StdLogger{Logger: log.New(&buffer, "", 0)}
+	logger.Log(Level(0), "unset level")
+
+	if formatted := fmt.Sprintf("%+v", *logger); !strings.Contains(formatted, "MinLevel:Level(0)") {
+		t.Errorf("Expected the unset minimum level to be formatted, but got %s", formatted)
+	}
+	if buffer.String() != "[Level(0)] unset level\n" {
+		t.Errorf("Expected the unset level to be written, but got %s", buffer.String())
+	}
+}
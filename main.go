@@ -5,17 +5,20 @@ import (
 	"azure-adt-example/digitaltwin"
 	"azure-adt-example/digitaltwin/models/rec33"
 	"azure-adt-example/digitaltwin/query"
+	"azure-adt-example/logging"
 	"fmt"
 	"log"
 )
 
 func main() {
-	config := azuread.NewTwinConfiguration()
+	config, err := azuread.LoadTwinConfiguration(azuread.LoadOptions{Logger: logging.NewStdLogger(nil, logging.LevelInfo)})
+	if err != nil {
+		log.Fatal(err)
+	}
 	client := digitaltwin.NewClient(config, nil)
 
 	from := rec33.Company{}
 	builder := query.NewBuilder(from, false, false)
-	var err error
 	if err = builder.AddJoin(from, rec33.Building{}, "owns", false, false); err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,150 @@
+package pipeline
+
+import (
+	"azure-adt-example/logging"
+	"azure-adt-example/retry"
+	"azure-adt-example/tracing"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Handler sends a request and returns its response.
+type Handler func(req *http.Request) (*http.Response, error)
+
+// Interceptor sees each request before it is sent, and its response or error afterwards. It
+// continues the chain by calling next, and can instead return a response or error of its own,
+// such as to inject faults in tests. Requests should be cloned before they are changed.
+type Interceptor func(req *http.Request, next Handler) (*http.Response, error)
+
+// Chain creates a Handler which passes each request through the interceptors in order before
+// sending it with the handler. The first interceptor is the outermost, seeing the request first
+// and the response last.
+func Chain(handler Handler, interceptors ...Interceptor) Handler {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], handler
+		handler = func(req *http.Request) (*http.Response, error) {
+			return interceptor(req, next)
+		}
+	}
+	return handler
+}
+
+// RoundTripperFunc adapts a function to the http.RoundTripper interface.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Transport creates an http.RoundTripper which passes each request through the interceptors
+// before sending it with the base transport, or http.DefaultTransport if base is nil. It allows
+// the interceptors to be used with any http.Client.
+func Transport(base http.RoundTripper, interceptors ...Interceptor) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return RoundTripperFunc(Chain(base.RoundTrip, interceptors...))
+}
+
+// Header creates an Interceptor which sets the header on every request.
+func Header(key string, value string) Interceptor {
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Header.Set(key, value)
+		return next(req)
+	}
+}
+
+// BearerToken creates an Interceptor which authorises every request with a token from the
+// source, such as the GetToken method of an azuread.TokenCredential.
+func BearerToken(source func(ctx context.Context) (string, error)) Interceptor {
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		token, err := source(req.Context())
+		if err != nil {
+			return nil, err
+		}
+
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		return next(req)
+	}
+}
+
+// Retry creates an Interceptor which retries transient failures of the rest of the chain
+// according to the policy.
+func Retry(policy *retry.Policy) Interceptor {
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		return policy.Do(req, next)
+	}
+}
+
+// Logging creates an Interceptor which logs the method, url, status and duration of every
+// request at the debug level, and every failed request at the error level.
+func Logging(logger logging.Logger) Interceptor {
+	logger = logging.OrDiscard(logger)
+
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next(req)
+
+		fields := []logging.Field{
+			logging.F(logging.FieldMethod, req.Method),
+			logging.F(logging.FieldURL, req.URL.String()),
+			logging.F(logging.FieldDuration, time.Since(start)),
+		}
+		if err != nil {
+			logger.Log(logging.LevelError, "request failed", append(fields, logging.F(logging.FieldError, err))...)
+		} else {
+			logger.Log(logging.LevelDebug, "request completed", append(fields, logging.F(logging.FieldStatus, resp.StatusCode))...)
+		}
+
+		return resp, err
+	}
+}
+
+// Tracing creates an Interceptor which starts a span for every request, propagating it to the
+// service in the W3C traceparent header, and records the number, errors and latency of the
+// requests with the meter. The tracer and meter may be nil.
+func Tracing(tracer tracing.Tracer, meter tracing.Meter) Interceptor {
+	tracer = tracing.TracerOrNoop(tracer)
+	meter = tracing.MeterOrNoop(meter)
+
+	requests := meter.Counter(tracing.MetricRequests)
+	failures := meter.Counter(tracing.MetricErrors)
+	duration := meter.Histogram(tracing.MetricRequestDuration)
+
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		ctx, span := tracer.Start(req.Context(), fmt.Sprintf("%s %s", tracing.SpanHTTPRequest, req.Method),
+			tracing.Attr(tracing.AttributeMethod, req.Method),
+			tracing.Attr(tracing.AttributeURL, req.URL.String()))
+		defer span.End()
+
+		req = req.Clone(ctx)
+		if spanContext := span.SpanContext(); spanContext.IsValid() {
+			req.Header.Set(tracing.TraceParentHeader, spanContext.TraceParent())
+		}
+
+		start := time.Now()
+		resp, err := next(req)
+
+		attributes := []tracing.Attribute{tracing.Attr(tracing.AttributeMethod, req.Method)}
+		if err != nil {
+			span.RecordError(err)
+			failures.Add(ctx, 1, attributes...)
+		} else {
+			attributes = append(attributes, tracing.Attr(tracing.AttributeStatus, resp.StatusCode))
+			span.SetAttributes(tracing.Attr(tracing.AttributeStatus, resp.StatusCode))
+			if resp.StatusCode >= http.StatusBadRequest {
+				span.RecordError(fmt.Errorf("non-success status code returned: %d", resp.StatusCode))
+				failures.Add(ctx, 1, attributes...)
+			}
+		}
+
+		requests.Add(ctx, 1, attributes...)
+		duration.Record(ctx, time.Since(start).Seconds(), attributes...)
+
+		return resp, err
+	}
+}
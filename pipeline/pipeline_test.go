@@ -0,0 +1,189 @@
+package pipeline
+
+import (
+	"azure-adt-example/retry"
+	"azure-adt-example/tracing"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingInterceptor creates an Interceptor which records when it sees the request and the
+// response.
+func recordingInterceptor(name string, calls *[]string) Interceptor {
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		*calls = append(*calls, name+" request")
+		resp, err := next(req)
+		*calls = append(*calls, name+" response")
+		return resp, err
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	handler := Chain(func(req *http.Request) (*http.Response, error) {
+		calls = append(calls, "send")
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}, recordingInterceptor("first", &calls), recordingInterceptor("second", &calls))
+
+	req, _ := http.NewRequest("GET", "https://example", nil)
+	if _, err := handler(req); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	expected := "first request,second request,send,second response,first response"
+	if strings.Join(calls, ",") != expected {
+		t.Errorf("Expected calls %s, but got %s", expected, strings.Join(calls, ","))
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		received = req.Header
+	}))
+	defer server.Close()
+
+	tokens := BearerToken(func(ctx context.Context) (string, error) {
+		return "token1", nil
+	})
+	client := &http.Client{Transport: Transport(nil, Header("x-custom", "value1"), tokens)}
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if received.Get("x-custom") != "value1" || received.Get("Authorization") != "Bearer token1" {
+		t.Errorf("Expected the interceptor headers, but got %v", received)
+	}
+	if req.Header.Get("x-custom") != "" {
+		t.Error("Expected the original request to be unchanged")
+	}
+}
+
+func TestBearerToken_Error(t *testing.T) {
+	sent := false
+	handler := Chain(func(req *http.Request) (*http.Response, error) {
+		sent = true
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}, BearerToken(func(ctx context.Context) (string, error) {
+		return "", errors.New("no token")
+	}))
+
+	req, _ := http.NewRequest("GET", "https://example", nil)
+	if _, err := handler(req); err == nil || err.Error() != "no token" {
+		t.Errorf("Expected the token error, but got %v", err)
+	}
+	if sent {
+		t.Error("Expected the request to not be sent")
+	}
+}
+
+func TestRetry_InjectedFault(t *testing.T) {
+	attempts := 0
+	faults := func(req *http.Request, next Handler) (*http.Response, error) {
+		attempts++
+		if attempts == 1 {
+			return &http.Response{StatusCode: http.StatusServiceUnavailable, Body: http.NoBody}, nil
+		}
+		return next(req)
+	}
+
+	policy := &retry.Policy{MaxAttempts: 3, BaseDelay: time.Millisecond, StatusCodes: []int{http.StatusServiceUnavailable}}
+	handler := Chain(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK}, nil
+	}, Retry(policy), faults)
+
+	req, _ := http.NewRequest("GET", "https://example", nil)
+	resp, err := handler(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the fault to be retried, but got %v %v", resp, err)
+	}
+	if attempts != 2 {
+		t.Errorf("Expected 2 attempts, but got %d", attempts)
+	}
+}
+
+func TestDecompress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("Expected gzip to be accepted, but got '%s'", req.Header.Get("Accept-Encoding"))
+		}
+
+		w.Header().Set("Content-Encoding", "gzip")
+		if req.URL.Path == "/empty" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		writer := gzip.NewWriter(w)
+		_, _ = writer.Write([]byte(`{ "value": [] }`))
+		_ = writer.Close()
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: Transport(nil, Decompress())}
+
+	for path, expected := range map[string]string{"/page": `{ "value": [] }`, "/empty": ""} {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatalf("Expected nil error, but got %v", err)
+		}
+
+		body, err := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil || string(body) != expected {
+			t.Errorf("Expected body '%s' for %s, but got '%s' (%v)", expected, path, string(body), err)
+		}
+		if resp.Header.Get("Content-Encoding") != "" || !resp.Uncompressed {
+			t.Errorf("Expected the response for %s to be marked as decompressed", path)
+		}
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	options := DefaultTransportOptions()
+	options.MaxConnsPerHost = 8
+	transport := NewTransport(options)
+
+	if !transport.ForceAttemptHTTP2 || transport.MaxIdleConnsPerHost != 100 || transport.MaxConnsPerHost != 8 || transport.IdleConnTimeout != 90*time.Second {
+		t.Errorf("Unexpected transport %+v", transport)
+	}
+}
+
+func TestTracing(t *testing.T) {
+	var traceParent string
+	exporter := tracing.NewInMemoryExporter()
+	handler := Chain(func(req *http.Request) (*http.Response, error) {
+		traceParent = req.Header.Get(tracing.TraceParentHeader)
+		return &http.Response{StatusCode: http.StatusTooManyRequests}, nil
+	}, Tracing(exporter, exporter))
+
+	req, _ := http.NewRequest("GET", "https://example", nil)
+	if _, err := handler(req); err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	spans := exporter.Spans()
+	if len(spans) != 1 || spans[0].Name != "HTTP GET" {
+		t.Fatalf("Expected a single HTTP GET span, but got %v", spans)
+	}
+	if traceParent != spans[0].SpanContext.TraceParent() {
+		t.Errorf("Expected traceparent %s, but got %s", spans[0].SpanContext.TraceParent(), traceParent)
+	}
+	if spans[0].Attributes[tracing.AttributeStatus] != http.StatusTooManyRequests || spans[0].Err == nil {
+		t.Errorf("Expected a failed span with the status, but got %+v", spans[0])
+	}
+	if exporter.Sum(tracing.MetricRequests) != 1 || exporter.Sum(tracing.MetricErrors) != 1 || len(exporter.Measurements(tracing.MetricRequestDuration)) != 1 {
+		t.Error("Expected a request, an error and a duration to be recorded")
+	}
+}
@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// TransportOptions tunes the connections made by a transport created with NewTransport.
+type TransportOptions struct {
+	// MaxIdleConns is the number of idle connections kept open across every host.
+	MaxIdleConns int
+
+	// MaxIdleConnsPerHost is the number of idle connections kept open to each host. Requests
+	// to an Azure Digital Twin instance all go to the same host, so this limits how many
+	// concurrent requests can reuse a connection.
+	MaxIdleConnsPerHost int
+
+	// MaxConnsPerHost limits the number of connections to each host. Zero means no limit.
+	MaxConnsPerHost int
+
+	// IdleConnTimeout is how long an idle connection is kept open.
+	IdleConnTimeout time.Duration
+
+	// DialTimeout and KeepAlive configure each new connection.
+	DialTimeout time.Duration
+	KeepAlive   time.Duration
+
+	// TLSHandshakeTimeout limits the time spent on the TLS handshake of a new connection.
+	TLSHandshakeTimeout time.Duration
+
+	// ResponseHeaderTimeout limits the time spent waiting for the response headers after the
+	// request has been sent. Zero means no limit, leaving the request context to decide.
+	ResponseHeaderTimeout time.Duration
+}
+
+// DefaultTransportOptions returns the options used by DefaultHTTPClient.
+func DefaultTransportOptions() TransportOptions {
+	return TransportOptions{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 100,
+		IdleConnTimeout:     90 * time.Second,
+		DialTimeout:         30 * time.Second,
+		KeepAlive:           30 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+}
+
+// NewTransport creates a transport which keeps connections alive between requests, and uses
+// HTTP/2 when the server supports it. Proxies are taken from the environment.
+func NewTransport(options TransportOptions) *http.Transport {
+	dialer := &net.Dialer{Timeout: options.DialTimeout, KeepAlive: options.KeepAlive}
+
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          options.MaxIdleConns,
+		MaxIdleConnsPerHost:   options.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       options.MaxConnsPerHost,
+		IdleConnTimeout:       options.IdleConnTimeout,
+		TLSHandshakeTimeout:   options.TLSHandshakeTimeout,
+		ResponseHeaderTimeout: options.ResponseHeaderTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+// DefaultHTTPClient is shared by every Client and credential which has not been given an HTTP
+// client, so that connections are reused between them.
+var DefaultHTTPClient = &http.Client{Transport: NewTransport(DefaultTransportOptions())}
+
+// Decompress creates an Interceptor which asks for responses to be compressed with gzip, and
+// decompresses them as they are read. Requests which already set Accept-Encoding are left
+// unchanged.
+func Decompress() Interceptor {
+	return func(req *http.Request, next Handler) (*http.Response, error) {
+		if req.Header.Get("Accept-Encoding") == "" {
+			req = req.Clone(req.Context())
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+
+		resp, err := next(req)
+		if err != nil || !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+			return resp, err
+		}
+
+		resp.Body = &gzipBody{body: resp.Body}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+
+		return resp, nil
+	}
+}
+
+// gzipBody decompresses a response body as it is read. The gzip reader is created on the first
+// read, so that an empty body reads as empty rather than failing.
+type gzipBody struct {
+	body   io.ReadCloser
+	reader *gzip.Reader
+}
+
+func (g *gzipBody) Read(p []byte) (int, error) {
+	if g.reader == nil {
+		reader, err := gzip.NewReader(g.body)
+		if err == io.EOF {
+			return 0, io.EOF
+		} else if err != nil {
+			return 0, fmt.Errorf("unable to decompress response: %w", err)
+		}
+		g.reader = reader
+	}
+	return g.reader.Read(p)
+}
+
+func (g *gzipBody) Close() error {
+	return g.body.Close()
+}
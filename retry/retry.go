@@ -0,0 +1,213 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxDrainBytes limits how much of a response which is retried is discarded to allow the
+// connection to be reused.
+const maxDrainBytes = 4096
+
+// Policy defines how requests to Azure which fail with a transient error are retried. A nil
+// Policy sends each request exactly once.
+type Policy struct {
+	// MaxAttempts is the total number of times a request is sent, including the first attempt.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. Each subsequent retry doubles the delay
+	// until MaxDelay is reached.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the delay calculated by the exponential backoff. If the service requests a
+	// longer delay using the Retry-After header then the request is not retried, and the
+	// response is returned instead.
+	MaxDelay time.Duration
+
+	// StatusCodes lists the HTTP status codes which are considered transient.
+	StatusCodes []int
+}
+
+// DefaultPolicy creates a Policy which retries throttled and unavailable responses up to 4
+// times in total.
+func DefaultPolicy() *Policy {
+	return &Policy{
+		MaxAttempts: 4,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    30 * time.Second,
+		StatusCodes: []int{
+			http.StatusRequestTimeout,
+			http.StatusTooManyRequests,
+			http.StatusInternalServerError,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// Do sends the request using the send function, retrying it whilst the response is transient
+// and attempts remain. The request body is re-read for each attempt using req.GetBody, which is
+// populated by http.NewRequest for in-memory bodies. Waiting between attempts stops if the
+// request context is cancelled.
+func (p *Policy) Do(req *http.Request, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	if p == nil || p.MaxAttempts <= 1 {
+		return send(req)
+	}
+
+	ctx := req.Context()
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.Body != nil && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("unable to reset request body for retry: %w", err)
+			}
+			req = req.Clone(ctx)
+			req.Body = body
+		}
+
+		resp, err := send(req)
+
+		if attempt >= p.MaxAttempts || !p.shouldRetry(req, resp, err) || ctx.Err() != nil {
+			return resp, err
+		}
+
+		delay, ok := p.delay(attempt, resp)
+		if !ok {
+			return resp, err
+		}
+
+		if resp != nil {
+			drainBody(resp.Body)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// IsRetryableStatus returns true if the status code is one the policy considers transient.
+func (p *Policy) IsRetryableStatus(statusCode int) bool {
+	if p == nil {
+		return false
+	}
+
+	for _, code := range p.StatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// idempotentKey is the context key marking a request as safe to send more than once.
+type idempotentKey struct{}
+
+// WithIdempotent marks the requests sent with the context as safe to send more than once, so that
+// they are retried after a transport error even if their method is not idempotent. This is
+// intended for requests which only read, such as queries sent with POST.
+func WithIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+// IsIdempotent returns true if the request can be sent more than once without changing the
+// outcome, either because its method is idempotent or because it was marked by WithIdempotent.
+func IsIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	marked, _ := req.Context().Value(idempotentKey{}).(bool)
+	return marked
+}
+
+// shouldRetry checks if the outcome of an attempt is transient. Transport errors and server
+// errors are only treated as transient if the request is idempotent, as the service may already
+// have applied it. Other requests are only retried when throttled or unavailable, as the service
+// has not processed them. The request context is checked separately.
+func (p *Policy) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if err != nil {
+		return IsIdempotent(req)
+	}
+
+	if !p.IsRetryableStatus(resp.StatusCode) {
+		return false
+	}
+
+	return IsIdempotent(req) || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable
+}
+
+// delay calculates how long to wait before the next attempt. A Retry-After header on the
+// response takes precedence, otherwise an exponential backoff with jitter is used. It returns
+// false if the Retry-After delay is longer than MaxDelay, in which case no retry is made.
+func (p *Policy) delay(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if retryAfter, ok := RetryAfter(resp.Header, time.Now()); ok {
+			return retryAfter, p.MaxDelay <= 0 || retryAfter <= p.MaxDelay
+		}
+	}
+
+	backoff := p.MaxDelay
+	if attempt <= 32 {
+		if exponential := p.BaseDelay << (attempt - 1); exponential > 0 && (p.MaxDelay <= 0 || exponential < p.MaxDelay) {
+			backoff = exponential
+		}
+	}
+
+	if backoff <= 0 {
+		return 0, true
+	}
+
+	// Use "equal jitter" so that there is always some delay, but concurrent clients spread out
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1)), true
+}
+
+// RetryAfter parses the Retry-After header, which may be either a number of seconds or an
+// HTTP date, into a duration relative to now.
+func RetryAfter(header http.Header, now time.Time) (time.Duration, bool) {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := date.Sub(now)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+// drainBody reads the remainder of a discarded response so the connection can be reused. Larger
+// bodies are closed without being read, which closes the connection.
+func drainBody(body io.ReadCloser) {
+	if body == nil {
+		return
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(body, maxDrainBytes))
+	_ = body.Close()
+}
@@ -0,0 +1,350 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestPolicy(maxAttempts int) *Policy {
+	policy := DefaultPolicy()
+	policy.MaxAttempts = maxAttempts
+	policy.BaseDelay = time.Millisecond
+	policy.MaxDelay = 5 * time.Millisecond
+	return policy
+}
+
+func TestPolicy_Do_RetriesTransientStatus(t *testing.T) {
+	attempts := 0
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		body, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(body))
+		if attempts < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, "done")
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("POST", server.URL, strings.NewReader("payload"))
+
+	resp, err := newTestPolicy(4).Do(req, http.DefaultClient.Do)
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, but got %d", resp.StatusCode)
+	}
+
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, but got %d", attempts)
+	}
+
+	for i, body := range bodies {
+		if body != "payload" {
+			t.Errorf("Expected attempt %d to send body 'payload', but got '%s'", i+1, body)
+		}
+	}
+}
+
+func TestPolicy_Do_StopsAtMaxAttempts(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+
+	resp, err := newTestPolicy(3).Do(req, http.DefaultClient.Do)
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected final status 503, but got %d", resp.StatusCode)
+	}
+
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, but got %d", attempts)
+	}
+}
+
+func TestPolicy_Do_DoesNotRetryPermanentStatus(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+
+	resp, err := newTestPolicy(3).Do(req, http.DefaultClient.Do)
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+
+	if attempts != 1 {
+		t.Errorf("Expected a single attempt, but got %d", attempts)
+	}
+}
+
+func TestPolicy_Do_ContextCancelledWhileWaiting(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL, nil)
+
+	policy := newTestPolicy(3)
+	policy.MaxDelay = time.Minute
+	_, err := policy.Do(req, http.DefaultClient.Do)
+	if err != context.DeadlineExceeded {
+		t.Errorf("Expected context.DeadlineExceeded, but got %v", err)
+	}
+}
+
+func TestPolicy_Do_NilPolicy(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+
+	var policy *Policy
+	resp, err := policy.Do(req, http.DefaultClient.Do)
+	if err != nil {
+		t.Logf("Expected nil error, but got %v", err)
+		t.FailNow()
+	}
+	defer resp.Body.Close()
+
+	if attempts != 1 {
+		t.Errorf("Expected a single attempt, but got %d", attempts)
+	}
+}
+
+func TestPolicy_delay(t *testing.T) {
+	policy := &Policy{MaxAttempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		name    string
+		attempt int
+		min     time.Duration
+		max     time.Duration
+	}{
+		{"FirstRetry", 1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"SecondRetry", 2, 100 * time.Millisecond, 200 * time.Millisecond},
+		{"Capped", 8, 500 * time.Millisecond, time.Second},
+		{"Overflow", 70, 500 * time.Millisecond, time.Second},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, ok := policy.delay(test.attempt, nil)
+			if !ok || actual < test.min || actual > test.max {
+				t.Errorf("Expected delay between %v and %v, but got %v", test.min, test.max, actual)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2022, 6, 22, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{"NotSet", "", 0, false},
+		{"Seconds", "5", 5 * time.Second, true},
+		{"HttpDate", "Wed, 22 Jun 2022 09:00:30 GMT", 30 * time.Second, true},
+		{"DateInPast", "Wed, 22 Jun 2022 08:00:00 GMT", 0, true},
+		{"Invalid", "soon", 0, false},
+		{"Negative", "-1", 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			header := http.Header{}
+			if test.value != "" {
+				header.Set("Retry-After", test.value)
+			}
+
+			actual, ok := RetryAfter(header, now)
+			if ok != test.ok || actual != test.expected {
+				t.Errorf("Expected (%v, %t), but got (%v, %t)", test.expected, test.ok, actual, ok)
+			}
+		})
+	}
+}
+
+func TestPolicy_Do_TransportErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		idempotent bool
+		expected   int32
+	}{
+		{"Post", "POST", false, 1},
+		{"MarkedPost", "POST", true, 3},
+		{"Get", "GET", false, 3},
+		{"Put", "PUT", false, 3},
+		{"Delete", "DELETE", false, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				atomic.AddInt32(&attempts, 1)
+				// Close the connection without a response, as a reset connection would
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				_ = conn.Close()
+			}))
+			defer server.Close()
+
+			ctx := context.Background()
+			if test.idempotent {
+				ctx = WithIdempotent(ctx)
+			}
+			req, _ := http.NewRequestWithContext(ctx, test.method, server.URL, strings.NewReader("payload"))
+
+			client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+			if _, err := newTestPolicy(3).Do(req, client.Do); err == nil {
+				t.Error("Expected a transport error")
+			}
+
+			if actual := atomic.LoadInt32(&attempts); actual != test.expected {
+				t.Errorf("Expected %d attempts, but got %d", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPolicy_Do_ServerErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		idempotent bool
+		status     int
+		expected   int32
+	}{
+		{"PostInternalError", "POST", false, http.StatusInternalServerError, 1},
+		{"PostBadGateway", "POST", false, http.StatusBadGateway, 1},
+		{"PostUnavailable", "POST", false, http.StatusServiceUnavailable, 3},
+		{"PostThrottled", "POST", false, http.StatusTooManyRequests, 3},
+		{"MarkedPostInternalError", "POST", true, http.StatusInternalServerError, 3},
+		{"PutInternalError", "PUT", false, http.StatusInternalServerError, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				atomic.AddInt32(&attempts, 1)
+				w.WriteHeader(test.status)
+			}))
+			defer server.Close()
+
+			ctx := context.Background()
+			if test.idempotent {
+				ctx = WithIdempotent(ctx)
+			}
+			req, _ := http.NewRequestWithContext(ctx, test.method, server.URL, strings.NewReader("payload"))
+
+			resp, err := newTestPolicy(3).Do(req, http.DefaultClient.Do)
+			if err != nil {
+				t.Fatalf("Expected nil error, but got %v", err)
+			}
+			resp.Body.Close()
+
+			if actual := atomic.LoadInt32(&attempts); actual != test.expected {
+				t.Errorf("Expected %d attempts, but got %d", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestPolicy_Do_RetryAfterBeyondMaxDelay(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		attempts++
+		w.Header().Set("Retry-After", "86400")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL, nil)
+
+	start := time.Now()
+	resp, err := newTestPolicy(3).Do(req, http.DefaultClient.Do)
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests || attempts != 1 {
+		t.Errorf("Expected the throttled response after 1 attempt, but got %d after %d", resp.StatusCode, attempts)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected not to wait for the Retry-After delay, but took %s", elapsed)
+	}
+}
+
+func TestDrainBody_Limit(t *testing.T) {
+	body := &countingBody{Reader: strings.NewReader(strings.Repeat("x", 10*maxDrainBytes))}
+	drainBody(body)
+
+	if body.read > maxDrainBytes || !body.closed {
+		t.Errorf("Expected at most %d bytes to be read before closing, but read %d", maxDrainBytes, body.read)
+	}
+}
+
+// countingBody counts the bytes read from it.
+type countingBody struct {
+	io.Reader
+	read   int
+	closed bool
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	b.read += n
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	b.closed = true
+	return nil
+}
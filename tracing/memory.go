@@ -0,0 +1,175 @@
+package tracing
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SpanData is a span which has been recorded by an InMemoryExporter.
+type SpanData struct {
+	Name         string
+	SpanContext  SpanContext
+	ParentSpanId SpanId
+	Attributes   map[string]any
+	Err          error
+	StartTime    time.Time
+	EndTime      time.Time
+}
+
+// Measurement is a value recorded by a counter or histogram of an InMemoryExporter.
+type Measurement struct {
+	Value      float64
+	Attributes map[string]any
+}
+
+// InMemoryExporter is a Tracer and Meter which keeps every span and measurement in memory. It
+// is intended for tests, and for inspecting the behaviour of the library without a collector.
+type InMemoryExporter struct {
+	mu           sync.Mutex
+	spans        []SpanData
+	measurements map[string][]Measurement
+}
+
+// NewInMemoryExporter creates an empty InMemoryExporter.
+func NewInMemoryExporter() *InMemoryExporter {
+	return &InMemoryExporter{measurements: make(map[string][]Measurement)}
+}
+
+func (e *InMemoryExporter) Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, Span) {
+	parent := SpanFromContext(ctx).SpanContext()
+
+	span := &memorySpan{exporter: e, data: SpanData{
+		Name:        name,
+		SpanContext: SpanContext{TraceId: parent.TraceId, SpanId: newSpanId(), Sampled: true},
+		Attributes:  make(map[string]any),
+		StartTime:   time.Now(),
+	}}
+	if parent.IsValid() {
+		span.data.ParentSpanId = parent.SpanId
+	} else {
+		span.data.SpanContext.TraceId = newTraceId()
+	}
+	span.SetAttributes(attributes...)
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// Spans returns the spans which have ended, in the order they ended.
+func (e *InMemoryExporter) Spans() []SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]SpanData(nil), e.spans...)
+}
+
+// SpansNamed returns the spans with the given name which have ended.
+func (e *InMemoryExporter) SpansNamed(name string) []SpanData {
+	var spans []SpanData
+	for _, span := range e.Spans() {
+		if span.Name == name {
+			spans = append(spans, span)
+		}
+	}
+	return spans
+}
+
+// Measurements returns the values recorded by the counter or histogram with the given name.
+func (e *InMemoryExporter) Measurements(name string) []Measurement {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]Measurement(nil), e.measurements[name]...)
+}
+
+// Sum returns the total of the values recorded by the counter or histogram with the given name.
+func (e *InMemoryExporter) Sum(name string) float64 {
+	var sum float64
+	for _, measurement := range e.Measurements(name) {
+		sum += measurement.Value
+	}
+	return sum
+}
+
+func (e *InMemoryExporter) Counter(name string) Counter {
+	return memoryInstrument{exporter: e, name: name}
+}
+
+func (e *InMemoryExporter) Histogram(name string) Histogram {
+	return memoryInstrument{exporter: e, name: name}
+}
+
+func (e *InMemoryExporter) record(name string, value float64, attributes []Attribute) {
+	measurement := Measurement{Value: value, Attributes: make(map[string]any, len(attributes))}
+	for _, attribute := range attributes {
+		measurement.Attributes[attribute.Key] = attribute.Value
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.measurements[name] = append(e.measurements[name], measurement)
+}
+
+type memoryInstrument struct {
+	exporter *InMemoryExporter
+	name     string
+}
+
+func (i memoryInstrument) Add(_ context.Context, value int64, attributes ...Attribute) {
+	i.exporter.record(i.name, float64(value), attributes)
+}
+
+func (i memoryInstrument) Record(_ context.Context, value float64, attributes ...Attribute) {
+	i.exporter.record(i.name, value, attributes)
+}
+
+// memorySpan is a span which is added to its exporter when it ends.
+type memorySpan struct {
+	exporter *InMemoryExporter
+	mu       sync.Mutex
+	data     SpanData
+	ended    bool
+}
+
+func (s *memorySpan) SpanContext() SpanContext {
+	return s.data.SpanContext
+}
+
+func (s *memorySpan) SetAttributes(attributes ...Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, attribute := range attributes {
+		s.data.Attributes[attribute.Key] = attribute.Value
+	}
+}
+
+func (s *memorySpan) RecordError(err error) {
+	if err == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data.Err = err
+}
+
+func (s *memorySpan) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.EndTime = time.Now()
+	data := s.data
+	data.Attributes = make(map[string]any, len(s.data.Attributes))
+	for key, value := range s.data.Attributes {
+		data.Attributes[key] = value
+	}
+	s.mu.Unlock()
+
+	s.exporter.mu.Lock()
+	defer s.exporter.mu.Unlock()
+	s.exporter.spans = append(s.exporter.spans, data)
+}
@@ -0,0 +1,83 @@
+package tracing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// TraceParentHeader is the W3C Trace Context header which propagates the span context.
+const TraceParentHeader = "traceparent"
+
+// TraceId identifies a trace, which is shared by every span in it.
+type TraceId [16]byte
+
+// SpanId identifies a single span within a trace.
+type SpanId [8]byte
+
+func (t TraceId) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanId) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsValid is false for the all zero id, which the W3C specification reserves as invalid.
+func (t TraceId) IsValid() bool { return t != TraceId{} }
+func (s SpanId) IsValid() bool  { return s != SpanId{} }
+
+// SpanContext identifies a span, and whether the trace it belongs to is being recorded.
+type SpanContext struct {
+	TraceId TraceId
+	SpanId  SpanId
+	Sampled bool
+}
+
+// IsValid returns true if both the trace and span ids are set.
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceId.IsValid() && sc.SpanId.IsValid()
+}
+
+// TraceParent formats the span context as the value of the traceparent header, such as
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func (sc SpanContext) TraceParent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", sc.TraceId, sc.SpanId, flags)
+}
+
+// ParseTraceParent reads the span context from the value of a traceparent header.
+func ParseTraceParent(value string) (SpanContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}, fmt.Errorf("%s is not a valid traceparent", value)
+	}
+	if parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, fmt.Errorf("%s is not a valid traceparent", value)
+	}
+
+	var spanContext SpanContext
+	version, errVersion := hex.DecodeString(parts[0])
+	_, errTrace := hex.Decode(spanContext.TraceId[:], []byte(parts[1]))
+	_, errSpan := hex.Decode(spanContext.SpanId[:], []byte(parts[2]))
+	flags, errFlags := hex.DecodeString(parts[3])
+	if errVersion != nil || errTrace != nil || errSpan != nil || errFlags != nil || len(version) != 1 || !spanContext.IsValid() {
+		return SpanContext{}, fmt.Errorf("%s is not a valid traceparent", value)
+	}
+
+	spanContext.Sampled = flags[0]&1 == 1
+	return spanContext, nil
+}
+
+// newTraceId generates a random trace id.
+func newTraceId() TraceId {
+	var id TraceId
+	_, _ = rand.Read(id[:])
+	return id
+}
+
+// newSpanId generates a random span id.
+func newSpanId() SpanId {
+	var id SpanId
+	_, _ = rand.Read(id[:])
+	return id
+}
@@ -0,0 +1,163 @@
+package tracing
+
+import (
+	"context"
+)
+
+// The names of the spans started by the library.
+const (
+	SpanExecuteBuilder = "ExecuteBuilder"
+	SpanExecutePage    = "ExecutePage"
+	SpanFetchPage      = "FetchPage"
+	SpanCount          = "Count"
+	SpanAcquireToken   = "AcquireToken"
+	SpanHTTPRequest    = "HTTP"
+)
+
+// The keys of the attributes set on the spans and measurements of the library.
+const (
+	AttributeQuery       = "adt.query"
+	AttributePage        = "adt.page"
+	AttributePageSize    = "adt.page_size"
+	AttributeResultCount = "adt.result_count"
+	AttributeQueryCharge = "adt.query_charge"
+	AttributeCredential  = "adt.credential"
+	AttributeMethod      = "http.method"
+	AttributeURL         = "http.url"
+	AttributeStatus      = "http.status_code"
+)
+
+// The names of the counters and histograms recorded by the library.
+const (
+	// MetricRequests counts every request sent to the Azure Digital Twin instance.
+	MetricRequests = "adt.client.requests"
+
+	// MetricErrors counts the requests which failed or returned a non-success status code.
+	MetricErrors = "adt.client.errors"
+
+	// MetricRequestDuration records the latency of each request in seconds.
+	MetricRequestDuration = "adt.client.request.duration"
+
+	// MetricQueryCharge records the query units charged for each page of a query.
+	MetricQueryCharge = "adt.client.query.charge"
+)
+
+// Attribute is a named value describing a span or measurement.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Attr creates an Attribute with the given key and value.
+func Attr(key string, value any) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Tracer starts spans, and can adapt them to a tracing library such as OpenTelemetry.
+type Tracer interface {
+	// Start begins a span which is a child of the span in the context, if any, returning a
+	// context containing the new span.
+	Start(ctx context.Context, name string, attributes ...Attribute) (context.Context, Span)
+}
+
+// Span records a single operation. Implementations must be safe for concurrent use.
+type Span interface {
+	// SpanContext identifies the span, for propagation to the services it calls.
+	SpanContext() SpanContext
+
+	SetAttributes(attributes ...Attribute)
+
+	// RecordError marks the span as failed. A nil error is ignored.
+	RecordError(err error)
+
+	End()
+}
+
+// Meter creates the instruments which record measurements.
+type Meter interface {
+	Counter(name string) Counter
+	Histogram(name string) Histogram
+}
+
+// Counter records a value which only increases, such as the number of requests.
+type Counter interface {
+	Add(ctx context.Context, value int64, attributes ...Attribute)
+}
+
+// Histogram records the distribution of a value, such as the latency of requests.
+type Histogram interface {
+	Record(ctx context.Context, value float64, attributes ...Attribute)
+}
+
+// NoopTracer starts spans which record nothing, but still carry the span context of their
+// parent so that an incoming trace is propagated.
+var NoopTracer Tracer = noopTracer{}
+
+// NoopMeter creates instruments which record nothing.
+var NoopMeter Meter = noopMeter{}
+
+// TracerOrNoop returns the tracer, or NoopTracer if it is nil.
+func TracerOrNoop(tracer Tracer) Tracer {
+	if tracer == nil {
+		return NoopTracer
+	}
+	return tracer
+}
+
+// MeterOrNoop returns the meter, or NoopMeter if it is nil.
+func MeterOrNoop(meter Meter) Meter {
+	if meter == nil {
+		return NoopMeter
+	}
+	return meter
+}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	span := noopSpan{spanContext: SpanFromContext(ctx).SpanContext()}
+	return ContextWithSpan(ctx, span), span
+}
+
+type noopSpan struct {
+	spanContext SpanContext
+}
+
+func (s noopSpan) SpanContext() SpanContext { return s.spanContext }
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
+
+type noopMeter struct{}
+
+func (noopMeter) Counter(string) Counter     { return noopInstrument{} }
+func (noopMeter) Histogram(string) Histogram { return noopInstrument{} }
+
+type noopInstrument struct{}
+
+func (noopInstrument) Add(context.Context, int64, ...Attribute)      {}
+func (noopInstrument) Record(context.Context, float64, ...Attribute) {}
+
+// spanKey is the key of the current span in a context.
+type spanKey struct{}
+
+// ContextWithSpan returns a context containing the span, which becomes the parent of the spans
+// started with it.
+func ContextWithSpan(ctx context.Context, span Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// ContextWithSpanContext returns a context whose spans are children of a span in another
+// service, such as one identified by the traceparent header of an incoming request.
+func ContextWithSpanContext(ctx context.Context, spanContext SpanContext) context.Context {
+	return ContextWithSpan(ctx, noopSpan{spanContext: spanContext})
+}
+
+// SpanFromContext returns the current span of the context, or a span which records nothing if
+// there is none.
+func SpanFromContext(ctx context.Context) Span {
+	if span, ok := ctx.Value(spanKey{}).(Span); ok {
+		return span
+	}
+	return noopSpan{}
+}
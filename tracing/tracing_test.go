@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	header := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+	spanContext, err := ParseTraceParent(header)
+	if err != nil {
+		t.Fatalf("Expected nil error, but got %v", err)
+	}
+
+	if spanContext.TraceId.String() != "4bf92f3577b34da6a3ce929d0e0e4736" || spanContext.SpanId.String() != "00f067aa0ba902b7" || !spanContext.Sampled {
+		t.Errorf("Unexpected span context %+v", spanContext)
+	}
+	if spanContext.TraceParent() != header {
+		t.Errorf("Expected %s, but got %s", header, spanContext.TraceParent())
+	}
+}
+
+func TestParseTraceParent_Invalid(t *testing.T) {
+	for _, header := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bz-01",
+	} {
+		if _, err := ParseTraceParent(header); err == nil {
+			t.Errorf("Expected an error for %q", header)
+		}
+	}
+}
+
+func TestInMemoryExporter_Spans(t *testing.T) {
+	exporter := NewInMemoryExporter()
+
+	ctx, parent := exporter.Start(context.Background(), "parent", Attr(AttributeQuery, "SELECT"))
+	_, child := exporter.Start(ctx, "child")
+	child.RecordError(errors.New("failed"))
+	child.End()
+	parent.SetAttributes(Attr(AttributeResultCount, 3))
+	parent.End()
+	parent.End()
+
+	spans := exporter.Spans()
+	if len(spans) != 2 {
+		t.Fatalf("Expected 2 spans, but got %d", len(spans))
+	}
+
+	childData, parentData := spans[0], spans[1]
+	if childData.SpanContext.TraceId != parentData.SpanContext.TraceId {
+		t.Error("Expected the child to share the trace of the parent")
+	}
+	if childData.ParentSpanId != parentData.SpanContext.SpanId {
+		t.Errorf("Expected parent span %s, but got %s", parentData.SpanContext.SpanId, childData.ParentSpanId)
+	}
+	if childData.Err == nil || parentData.Err != nil {
+		t.Errorf("Expected only the child to have an error, but got %v and %v", childData.Err, parentData.Err)
+	}
+	if parentData.Attributes[AttributeQuery] != "SELECT" || parentData.Attributes[AttributeResultCount] != 3 {
+		t.Errorf("Unexpected attributes %v", parentData.Attributes)
+	}
+}
+
+func TestInMemoryExporter_Measurements(t *testing.T) {
+	exporter := NewInMemoryExporter()
+
+	exporter.Counter(MetricRequests).Add(context.Background(), 2, Attr(AttributeStatus, 200))
+	exporter.Counter(MetricRequests).Add(context.Background(), 1)
+	exporter.Histogram(MetricRequestDuration).Record(context.Background(), 0.5)
+
+	if exporter.Sum(MetricRequests) != 3 {
+		t.Errorf("Expected 3 requests, but got %v", exporter.Sum(MetricRequests))
+	}
+	if measurements := exporter.Measurements(MetricRequests); measurements[0].Attributes[AttributeStatus] != 200 {
+		t.Errorf("Unexpected attributes %v", measurements[0].Attributes)
+	}
+	if len(exporter.Measurements(MetricRequestDuration)) != 1 {
+		t.Error("Expected a single duration")
+	}
+}
+
+func TestNoopTracer_KeepsParent(t *testing.T) {
+	parent, _ := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := ContextWithSpanContext(context.Background(), parent)
+
+	ctx, span := TracerOrNoop(nil).Start(ctx, "child")
+	defer span.End()
+
+	if SpanFromContext(ctx).SpanContext() != parent {
+		t.Errorf("Expected the parent span context, but got %+v", SpanFromContext(ctx).SpanContext())
+	}
+}